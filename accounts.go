@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+)
+
+// Account is who an XML-RPC call acts as: the Micropub token to use and
+// where to use it.
+type Account struct {
+	Me               string `json:"me"`
+	MicropubEndpoint string `json:"micropub_endpoint"`
+	MediaEndpoint    string `json:"media_endpoint,omitempty"`
+	TokenEndpoint    string `json:"token_endpoint"`
+	Token            string `json:"token"`
+}
+
+// Logins holds the accounts signed in through the bridge's IndieAuth login
+// flow, keyed by a hash of the password the bridge issued for each, and
+// persists them to a JSON file. The file contains tokens and is only readable
+// by its owner.
+type Logins struct {
+	path string
+
+	mu       sync.Mutex
+	accounts map[string]*Account
+}
+
+// OpenLogins loads the logins stored at path, or starts with none if the file
+// doesn't exist yet.
+func OpenLogins(path string) (*Logins, error) {
+	l := &Logins{path: path}
+
+	if err := loadJSON(path, &l.accounts); err != nil {
+		return nil, err
+	}
+
+	if l.accounts == nil {
+		l.accounts = map[string]*Account{}
+	}
+
+	return l, nil
+}
+
+// Get returns the account the bridge issued password for.
+func (l *Logins) Get(password string) (Account, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[hashToken(password)]
+	if !ok {
+		return Account{}, false
+	}
+	return *account, true
+}
+
+// Add records an account under a newly issued password.
+func (l *Logins) Add(password string, account Account) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.accounts[hashToken(password)] = &account
+	return l.save()
+}
+
+// save writes the logins to disk. The caller must hold l.mu.
+func (l *Logins) save() error {
+	return saveJSON(l.path, l.accounts)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BloggerService implements the handful of Blogger API methods that WordPress
+// clients still use. Their argument lists differ from the wp.* equivalents, so
+// they can't be served by WPService directly.
+type BloggerService struct {
+	wp *WPService
+}
+
+// Help implements xmlrpc.Helper.
+func (s *BloggerService) Help(method string) string {
+	switch method {
+	case "GetUsersBlogs":
+		return "Returns the blogs (Micropub destinations) the user can post to."
+	case "DeletePost":
+		return "Deletes a post with a Micropub delete request."
+	}
+	return ""
+}
+
+type BloggerGetUsersBlogsArgs struct {
+	AppKey   string
+	Username string
+	Password string
+}
+
+type BloggerGetUsersBlogsReply struct {
+	Blogs []UserBlog
+}
+
+func (s *BloggerService) GetUsersBlogs(req *http.Request, args *BloggerGetUsersBlogsArgs, reply *BloggerGetUsersBlogsReply) error {
+	log.WithFields(log.Fields{
+		"u": args.Username,
+	}).Info("---> blogger.GetUsersBlogs")
+
+	blogs, err := s.wp.usersBlogs(args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	reply.Blogs = blogs
+
+	return nil
+}
+
+type BloggerDeletePostArgs struct {
+	AppKey   string
+	PostID   string
+	Username string
+	Password string
+	Publish  bool
+}
+
+type BloggerDeletePostReply struct {
+	Success bool
+}
+
+func (s *BloggerService) DeletePost(req *http.Request, args *BloggerDeletePostArgs, reply *BloggerDeletePostReply) error {
+	log.WithFields(log.Fields{
+		"u":   args.Username,
+		"pid": args.PostID,
+	}).Info("---> blogger.DeletePost")
+
+	client, err := s.wp.checkPostAuth(args.PostID, args.Username, args.Password, scopeDelete)
+	if err != nil {
+		return err
+	}
+
+	if err := s.wp.trashPost(client, args.PostID); err != nil {
+		return err
+	}
+
+	reply.Success = true
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codykrieger/microbridge/indieauth"
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+// negativeCredentialTTL is how long a token the Micropub server (or token
+// endpoint) rejected is remembered as rejected.
+const negativeCredentialTTL = time.Minute
+
+// CredentialCache remembers the tokens that were accepted, along with the
+// Micropub config and token endpoint verification fetched for them, so that
+// authenticating an XML-RPC call doesn't cost a round trip upstream. Rejected
+// tokens are remembered too, for a shorter time. Tokens are only kept as
+// hashes.
+type CredentialCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*credential // see credentialKey
+}
+
+type credential struct {
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// Kinds of cached credential entries.
+const (
+	credentialConfig = "config:"
+	credentialToken  = "token:"
+)
+
+func NewCredentialCache(ttl time.Duration) *CredentialCache {
+	return &CredentialCache{ttl: ttl, now: time.Now, entries: map[string]*credential{}}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// credentialKey returns the key of the entry of the given kind for token and
+// the endpoint it was used with. The same token can be sent to several
+// endpoints, which needn't agree about it.
+func credentialKey(kind, token, endpoint string) string {
+	return kind + hashToken(token) + " " + endpoint
+}
+
+// Config returns the Micropub config for the client's token, fetching it if
+// it isn't cached.
+func (c *CredentialCache) Config(client *micropub.Client) (*micropub.Config, error) {
+	value, err := c.get(credentialKey(credentialConfig, client.Token, client.Endpoint), func() (interface{}, error) {
+		return client.GetConfig()
+	}, micropub.IsAuthError)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*micropub.Config), nil
+}
+
+// Token returns what the token endpoint says about token, verifying it if it
+// isn't cached.
+func (c *CredentialCache) Token(tokenEndpoint, token string) (*indieauth.Token, error) {
+	value, err := c.get(credentialKey(credentialToken, token, tokenEndpoint), func() (interface{}, error) {
+		return indieauth.VerifyToken(tokenEndpoint, token)
+	}, func(err error) bool {
+		return err == indieauth.ErrInvalidToken
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*indieauth.Token), nil
+}
+
+// get returns the cached entry for key, calling fetch if there is none.
+// Errors for which rejected returns true are cached; others (e.g. network
+// errors) aren't.
+func (c *CredentialCache) get(key string, fetch func() (interface{}, error), rejected func(error) bool) (interface{}, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	cred, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(cred.expires) {
+		return cred.value, cred.err
+	}
+
+	value, err := fetch()
+	switch {
+	case err == nil:
+		cred = &credential{value: value, expires: now.Add(c.ttl)}
+	case rejected(err):
+		cred = &credential{err: err, expires: now.Add(negativeCredentialTTL)}
+	default:
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cred
+
+	return value, err
+}
+
+// Invalidate forgets everything cached for token, with any endpoint, e.g.
+// after the Micropub server rejected it.
+func (c *CredentialCache) Invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hash := hashToken(token)
+	for key := range c.entries {
+		if strings.HasPrefix(key, credentialConfig+hash+" ") || strings.HasPrefix(key, credentialToken+hash+" ") {
+			delete(c.entries, key)
+		}
+	}
+}
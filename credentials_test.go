@@ -0,0 +1,215 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codykrieger/microbridge/indieauth"
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+// fakeClock is a clock for CredentialCache that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestCredentialCache(ttl time.Duration) (*CredentialCache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache := NewCredentialCache(ttl)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+// newCountingConfigServer returns a Micropub server that responds to config
+// queries with the given status, and a count of the queries it received.
+func newCountingConfigServer(t *testing.T, status int) (*httptest.Server, *int32) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestCredentialCacheTTL(t *testing.T) {
+	server, fetches := newCountingConfigServer(t, http.StatusOK)
+	cache, clock := newTestCredentialCache(time.Hour)
+	client := micropub.NewClient(server.URL, "token")
+
+	steps := []struct {
+		advance time.Duration
+		fetches int32
+	}{
+		{0, 1},
+		{30 * time.Minute, 1},
+		{30*time.Minute - time.Second, 1},
+		{time.Second, 2},
+		{time.Minute, 2},
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if _, err := cache.Config(client); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got := atomic.LoadInt32(fetches); got != step.fetches {
+			t.Errorf("step %d: got %d fetches, want %d", i, got, step.fetches)
+		}
+	}
+}
+
+func TestCredentialCacheNegative(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		fetches []int32 // after each of three calls, the last a negative TTL later
+	}{
+		{"rejected", http.StatusUnauthorized, []int32{1, 1, 2}},
+		{"forbidden", http.StatusForbidden, []int32{1, 1, 2}},
+		{"server error", http.StatusInternalServerError, []int32{1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, fetches := newCountingConfigServer(t, test.status)
+			cache, clock := newTestCredentialCache(time.Hour)
+			client := micropub.NewClient(server.URL, "token")
+
+			for i, want := range test.fetches {
+				if i == 2 {
+					clock.Advance(negativeCredentialTTL)
+				}
+				_, err := cache.Config(client)
+				if e, ok := err.(*micropub.Error); !ok || e.StatusCode != test.status {
+					t.Fatalf("call %d: got error %v, want a %d", i, err, test.status)
+				}
+				if got := atomic.LoadInt32(fetches); got != want {
+					t.Errorf("call %d: got %d fetches, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCredentialCacheKeys(t *testing.T) {
+	one, oneFetches := newCountingConfigServer(t, http.StatusOK)
+	two, twoFetches := newCountingConfigServer(t, http.StatusOK)
+	cache, _ := newTestCredentialCache(time.Hour)
+
+	for _, client := range []*micropub.Client{
+		micropub.NewClient(one.URL, "secret"),
+		micropub.NewClient(two.URL, "secret"),
+		micropub.NewClient(one.URL, "other"),
+		micropub.NewClient(one.URL, "secret"),
+	} {
+		if _, err := cache.Config(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The same token is checked with each endpoint, and each token with the
+	// same endpoint.
+	if got := atomic.LoadInt32(oneFetches); got != 2 {
+		t.Errorf("first endpoint: got %d fetches, want 2", got)
+	}
+	if got := atomic.LoadInt32(twoFetches); got != 1 {
+		t.Errorf("second endpoint: got %d fetches, want 1", got)
+	}
+
+	for key := range cache.entries {
+		if strings.Contains(key, "secret") {
+			t.Errorf("key %q holds the token", key)
+		}
+	}
+}
+
+func TestCredentialCacheInvalidate(t *testing.T) {
+	one, oneFetches := newCountingConfigServer(t, http.StatusOK)
+	two, twoFetches := newCountingConfigServer(t, http.StatusOK)
+	cache, _ := newTestCredentialCache(time.Hour)
+
+	clients := []*micropub.Client{
+		micropub.NewClient(one.URL, "token"),
+		micropub.NewClient(two.URL, "token"),
+		micropub.NewClient(one.URL, "other"),
+	}
+	fetchAll := func() {
+		for _, client := range clients {
+			if _, err := cache.Config(client); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	fetchAll()
+	cache.Invalidate("token")
+	fetchAll()
+
+	// Only "token" is fetched again, with both endpoints.
+	if got := atomic.LoadInt32(oneFetches); got != 3 {
+		t.Errorf("first endpoint: got %d fetches, want 3", got)
+	}
+	if got := atomic.LoadInt32(twoFetches); got != 2 {
+		t.Errorf("second endpoint: got %d fetches, want 2", got)
+	}
+}
+
+func TestCredentialCacheToken(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"me":"https://example.com/","scope":"create"}`))
+	}))
+	defer server.Close()
+
+	cache, clock := newTestCredentialCache(time.Hour)
+
+	for i := 0; i < 2; i++ {
+		token, err := cache.Token(server.URL, "good")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Me != "https://example.com/" {
+			t.Errorf("got me %q", token.Me)
+		}
+		if _, err := cache.Token(server.URL, "bad"); err != indieauth.ErrInvalidToken {
+			t.Errorf("got error %v, want ErrInvalidToken", err)
+		}
+	}
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("got %d fetches, want 2", got)
+	}
+
+	// The rejection expires long before the verified token does.
+	clock.Advance(negativeCredentialTTL)
+	cache.Token(server.URL, "good")
+	cache.Token(server.URL, "bad")
+	if got := atomic.LoadInt32(&fetches); got != 3 {
+		t.Errorf("got %d fetches, want 3", got)
+	}
+
+	// Invalidating a token forgets its verification too.
+	cache.Invalidate("good")
+	cache.Token(server.URL, "good")
+	if got := atomic.LoadInt32(&fetches); got != 4 {
+		t.Errorf("got %d fetches, want 4", got)
+	}
+}
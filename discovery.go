@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codykrieger/microbridge/indieauth"
+)
+
+// How long discovered endpoints are remembered. Failed discoveries are
+// remembered briefly too, so that a misconfigured client can't have the
+// bridge fetch a site on every call.
+const (
+	discoveryTTL         = time.Hour
+	negativeDiscoveryTTL = time.Minute
+)
+
+// EndpointCache remembers the endpoints sites advertise.
+type EndpointCache struct {
+	mu      sync.Mutex
+	entries map[string]*discovered // site URL -> endpoints
+}
+
+type discovered struct {
+	endpoints *indieauth.Endpoints
+	err       error
+	expires   time.Time
+}
+
+func NewEndpointCache() *EndpointCache {
+	return &EndpointCache{entries: map[string]*discovered{}}
+}
+
+// Get returns the endpoints advertised by the site at siteURL, discovering
+// them if they aren't cached.
+func (c *EndpointCache) Get(siteURL string) (*indieauth.Endpoints, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	d, ok := c.entries[siteURL]
+	c.mu.Unlock()
+	if ok && now.Before(d.expires) {
+		return d.endpoints, d.err
+	}
+
+	endpoints, err := indieauth.Discover(siteURL)
+	if err != nil {
+		d = &discovered{err: err, expires: now.Add(negativeDiscoveryTTL)}
+	} else {
+		d = &discovered{endpoints: endpoints, expires: now.Add(discoveryTTL)}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[siteURL] = d
+
+	return endpoints, err
+}
+
+// siteURL returns the site URL a username names, if it names one: a URL with
+// an http or https scheme, or, if hostNames is set, a host name like
+// example.com (which is taken to be served over https). Other usernames are
+// ignored.
+func siteURL(username string, hostNames bool) (string, bool) {
+	if strings.HasPrefix(username, "https://") || strings.HasPrefix(username, "http://") {
+		return username, true
+	}
+	if hostNames && strings.Contains(username, ".") && !strings.ContainsAny(username, "@: \t") {
+		return "https://" + username, true
+	}
+	return "", false
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestSiteURL(t *testing.T) {
+	tests := []struct {
+		username  string
+		hostNames bool
+		want      string
+		ok        bool
+	}{
+		{"https://example.com/", false, "https://example.com/", true},
+		{"http://example.com", false, "http://example.com", true},
+		{"example.com", false, "", false},
+		{"example.com", true, "https://example.com", true},
+		{"jane.doe", false, "", false},
+		{"jane@example.com", true, "", false},
+		{"jane", true, "", false},
+		{"ftp://example.com", true, "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := siteURL(test.username, test.hostNames)
+		if got != test.want || ok != test.ok {
+			t.Errorf("siteURL(%q, %v) = %q, %v; want %q, %v", test.username, test.hostNames, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package indieauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AuthRequest is an authorization request using the authorization code flow
+// with PKCE, as described in
+// https://indieauth.spec.indieweb.org/#authorization-request.
+type AuthRequest struct {
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+
+	Me          string
+	ClientID    string
+	RedirectURI string
+	Scope       string
+
+	// State and Verifier are random values generated by NewAuthRequest. The
+	// client must keep both until the user is redirected back to it.
+	State    string
+	Verifier string
+}
+
+// NewAuthRequest starts an authorization request for the user at me, whose
+// endpoints were found by Discover.
+func NewAuthRequest(endpoints *Endpoints, clientID, redirectURI, scope string) (*AuthRequest, error) {
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" {
+		return nil, errors.New("indieauth: " + endpoints.Me + " doesn't advertise an authorization and token endpoint")
+	}
+
+	state, err := RandomString(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthRequest{
+		AuthorizationEndpoint: endpoints.AuthorizationEndpoint,
+		TokenEndpoint:         endpoints.TokenEndpoint,
+		Me:                    endpoints.Me,
+		ClientID:              clientID,
+		RedirectURI:           redirectURI,
+		Scope:                 scope,
+		State:                 state,
+		Verifier:              verifier,
+	}, nil
+}
+
+// URL returns the authorization endpoint URL to send the user to.
+func (r *AuthRequest) URL() string {
+	challenge := sha256.Sum256([]byte(r.Verifier))
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {r.ClientID},
+		"redirect_uri":          {r.RedirectURI},
+		"state":                 {r.State},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"scope":                 {r.Scope},
+		"me":                    {r.Me},
+	}
+
+	sep := "?"
+	if strings.Contains(r.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return r.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// AccessToken is a token issued by a token endpoint.
+type AccessToken struct {
+	Token
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Redeem exchanges the authorization code the user was redirected back with
+// for an access token.
+func (r *AuthRequest) Redeem(code string) (*AccessToken, error) {
+	log.Info("indieauth: POST " + r.TokenEndpoint)
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {r.ClientID},
+		"redirect_uri":  {r.RedirectURI},
+		"code_verifier": {r.Verifier},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		msg := fmt.Sprintf("indieauth: token endpoint returned %s", resp.Status)
+		if body.Error != "" {
+			msg += ": " + body.Error
+		}
+		if body.ErrorDescription != "" {
+			msg += ": " + body.ErrorDescription
+		}
+		return nil, errors.New(msg)
+	}
+	if body.AccessToken.AccessToken == "" {
+		return nil, errors.New("indieauth: token endpoint returned no access token")
+	}
+
+	return &body.AccessToken, nil
+}
+
+// RandomString returns n random bytes, base64url-encoded.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,193 @@
+package indieauth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rel values of the endpoints a site can advertise.
+const (
+	RelAuthorizationEndpoint = "authorization_endpoint"
+	RelTokenEndpoint         = "token_endpoint"
+	RelMicropub              = "micropub"
+	RelMediaEndpoint         = "media-endpoint"
+)
+
+// Endpoints are the endpoints a site advertises, as absolute URLs. Endpoints
+// the site doesn't advertise are empty.
+type Endpoints struct {
+	// Me is the site's canonical URL, after following redirects.
+	Me string
+
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	Micropub              string
+	MediaEndpoint         string
+}
+
+// maxDiscoveryBody is how much of a site's HTML is searched for <link>
+// elements. They belong in the <head>, so this is plenty.
+const maxDiscoveryBody = 1 << 20
+
+// discoveryClient fetches the sites endpoints are discovered from. Site URLs
+// come from whoever calls the bridge, so it refuses to connect to loopback,
+// private and link-local addresses (redirects included), which would let
+// callers probe the bridge's own network.
+var discoveryClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Control: refuseNonPublic}).DialContext,
+	},
+}
+
+func refuseNonPublic(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return fmt.Errorf("indieauth: refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// isPublic reports whether ip is a public unicast address.
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// Discover fetches siteURL and looks for the endpoints it advertises, first
+// in its HTTP Link headers and then in the <link> elements of its HTML, as
+// described in https://indieauth.spec.indieweb.org/#discovery-by-clients.
+// Sites on non-public addresses are refused.
+func Discover(siteURL string) (*Endpoints, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("indieauth: site URL must be http or https")
+	}
+
+	log.Info("indieauth: GET " + siteURL)
+
+	resp, err := discoveryClient.Get(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("indieauth: " + siteURL + " returned " + resp.Status)
+	}
+
+	base := resp.Request.URL
+	rels := parseLinkHeaders(resp.Header["Link"])
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDiscoveryBody))
+		if err != nil {
+			return nil, err
+		}
+		for rel, href := range parseHTMLLinks(string(body)) {
+			if _, ok := rels[rel]; !ok {
+				rels[rel] = href
+			}
+		}
+	}
+
+	resolve := func(rel string) string {
+		href, ok := rels[rel]
+		if !ok {
+			return ""
+		}
+		ref, err := base.Parse(href)
+		if err != nil {
+			log.Warnf("indieauth: ignoring invalid %s link '%s'", rel, href)
+			return ""
+		}
+		return ref.String()
+	}
+
+	return &Endpoints{
+		Me:                    base.String(),
+		AuthorizationEndpoint: resolve(RelAuthorizationEndpoint),
+		TokenEndpoint:         resolve(RelTokenEndpoint),
+		Micropub:              resolve(RelMicropub),
+		MediaEndpoint:         resolve(RelMediaEndpoint),
+	}, nil
+}
+
+var linkHeaderRe = regexp.MustCompile(`<([^>]*)>([^,]*)`)
+var relParamRe = regexp.MustCompile(`(?i);\s*rel\s*=\s*(?:"([^"]*)"|([^\s;,]+))`)
+
+// parseLinkHeaders returns the first href given for each rel in the Link
+// headers, e.g. `<https://example.com/micropub>; rel="micropub"`.
+func parseLinkHeaders(headers []string) map[string]string {
+	rels := map[string]string{}
+
+	for _, header := range headers {
+		for _, m := range linkHeaderRe.FindAllStringSubmatch(header, -1) {
+			rel := relParamRe.FindStringSubmatch(m[2])
+			if rel == nil {
+				continue
+			}
+			for _, r := range strings.Fields(rel[1] + rel[2]) {
+				if _, ok := rels[r]; !ok {
+					rels[r] = m[1]
+				}
+			}
+		}
+	}
+
+	return rels
+}
+
+var elementRe = regexp.MustCompile(`(?is)<link\s([^>]*)>`)
+var attrRe = regexp.MustCompile(`(?s)([\w-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// parseHTMLLinks returns the first href given for each rel by the <link>
+// elements of an HTML document. Links in <a> elements are ignored: they can
+// come from anyone who can post a comment on the site.
+func parseHTMLLinks(body string) map[string]string {
+	rels := map[string]string{}
+
+	for _, m := range elementRe.FindAllStringSubmatch(body, -1) {
+		attrs := map[string]string{}
+		for _, a := range attrRe.FindAllStringSubmatch(m[1], -1) {
+			attrs[strings.ToLower(a[1])] = a[2] + a[3] + a[4]
+		}
+
+		href, ok := attrs["href"]
+		if !ok {
+			continue
+		}
+		for _, r := range strings.Fields(attrs["rel"]) {
+			if _, ok := rels[r]; !ok {
+				rels[r] = unescapeHTML(href)
+			}
+		}
+	}
+
+	return rels
+}
+
+var htmlEntities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'")
+
+func unescapeHTML(s string) string {
+	return htmlEntities.Replace(s)
+}
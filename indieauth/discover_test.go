@@ -0,0 +1,181 @@
+package indieauth
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLinkHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    map[string]string
+	}{
+		{"none", nil, map[string]string{}},
+		{
+			"quoted rel",
+			[]string{`<https://example.com/micropub>; rel="micropub"`},
+			map[string]string{"micropub": "https://example.com/micropub"},
+		},
+		{
+			"unquoted rel",
+			[]string{`<https://example.com/micropub>; rel=micropub`},
+			map[string]string{"micropub": "https://example.com/micropub"},
+		},
+		{
+			"relative URL",
+			[]string{`</micropub>; rel="micropub"`},
+			map[string]string{"micropub": "/micropub"},
+		},
+		{
+			"multiple rels",
+			[]string{`</auth>; rel="authorization_endpoint token_endpoint"`},
+			map[string]string{"authorization_endpoint": "/auth", "token_endpoint": "/auth"},
+		},
+		{
+			"several links in one header",
+			[]string{`</micropub>; rel="micropub", </media>; type="text/html"; rel="media-endpoint"`},
+			map[string]string{"micropub": "/micropub", "media-endpoint": "/media"},
+		},
+		{
+			"several headers",
+			[]string{`</micropub>; rel="micropub"`, `</token>; REL="token_endpoint"`},
+			map[string]string{"micropub": "/micropub", "token_endpoint": "/token"},
+		},
+		{
+			"first link wins",
+			[]string{`</one>; rel="micropub", </two>; rel="micropub"`},
+			map[string]string{"micropub": "/one"},
+		},
+		{
+			"no rel",
+			[]string{`</style.css>; type="text/css"`},
+			map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseLinkHeaders(test.headers); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseHTMLLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{"none", `<html><head></head></html>`, map[string]string{}},
+		{
+			"double quotes",
+			`<link rel="micropub" href="https://example.com/micropub">`,
+			map[string]string{"micropub": "https://example.com/micropub"},
+		},
+		{
+			"single quotes",
+			`<link rel='micropub' href='/micropub'>`,
+			map[string]string{"micropub": "/micropub"},
+		},
+		{
+			"unquoted",
+			`<link rel=micropub href=/micropub />`,
+			map[string]string{"micropub": "/micropub"},
+		},
+		{
+			"href first",
+			`<link href="/micropub" rel="micropub">`,
+			map[string]string{"micropub": "/micropub"},
+		},
+		{
+			"multiple rels",
+			`<link rel="authorization_endpoint token_endpoint" href="/auth">`,
+			map[string]string{"authorization_endpoint": "/auth", "token_endpoint": "/auth"},
+		},
+		{
+			"case and line breaks",
+			"<LINK\n  REL=\"micropub\"\n  HREF=\"/micropub\">",
+			map[string]string{"micropub": "/micropub"},
+		},
+		{
+			"escaped href",
+			`<link rel="micropub" href="/micropub?a=1&amp;b=2">`,
+			map[string]string{"micropub": "/micropub?a=1&b=2"},
+		},
+		{
+			"first link wins",
+			`<link rel="micropub" href="/one"><link rel="micropub" href="/two">`,
+			map[string]string{"micropub": "/one"},
+		},
+		{
+			"no href",
+			`<link rel="micropub">`,
+			map[string]string{},
+		},
+		{
+			"a ignored",
+			`<a rel="micropub" href="/evil">x</a><link rel="micropub" href="/micropub">`,
+			map[string]string{"micropub": "/micropub"},
+		},
+		{
+			"only a",
+			`<a rel="token_endpoint" href="/evil">x</a>`,
+			map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseHTMLLinks(test.body); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			if got := isPublic(net.ParseIP(test.ip)); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</micropub>; rel="micropub"`)
+	}))
+	defer server.Close()
+
+	_, err := Discover(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("got %v, want a non-public address error", err)
+	}
+}
@@ -0,0 +1,96 @@
+package indieauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidToken is returned by VerifyToken when the token endpoint doesn't
+// accept the token.
+var ErrInvalidToken = errors.New("indieauth: invalid token")
+
+// Token describes an access token, as reported by the token endpoint that
+// issued it.
+type Token struct {
+	Me       string `json:"me"`
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+// Scopes returns the token's scopes.
+func (t *Token) Scopes() []string {
+	return strings.Fields(t.Scope)
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyToken asks the token endpoint about token, as described in
+// https://indieauth.spec.indieweb.org/#access-token-verification.
+func VerifyToken(tokenEndpoint, token string) (*Token, error) {
+	h := &http.Client{}
+
+	log.Info("indieauth: GET " + tokenEndpoint)
+
+	req, err := http.NewRequest(http.MethodGet, tokenEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, ErrInvalidToken
+	default:
+		return nil, fmt.Errorf("indieauth: token endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token
+		// Active is only sent by token endpoints that implement token
+		// introspection, which report inactive tokens with a 200.
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	if (body.Active != nil && !*body.Active) || body.Me == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &body.Token, nil
+}
+
+// SameProfile reports whether two profile URLs identify the same user. The
+// scheme and a trailing slash are ignored.
+func SameProfile(a, b string) bool {
+	return normalizeProfile(a) == normalizeProfile(b)
+}
+
+func normalizeProfile(u string) string {
+	if i := strings.Index(u, "://"); i != -1 {
+		u = u[i+3:]
+	}
+	return strings.TrimSuffix(u, "/")
+}
@@ -0,0 +1,123 @@
+package indieauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   *Token
+		err    error
+	}{
+		{
+			"valid",
+			http.StatusOK,
+			`{"me":"https://example.com/","client_id":"https://app.example/","scope":"create update"}`,
+			&Token{Me: "https://example.com/", ClientID: "https://app.example/", Scope: "create update"},
+			nil,
+		},
+		{
+			"active",
+			http.StatusOK,
+			`{"active":true,"me":"https://example.com/","scope":"create"}`,
+			&Token{Me: "https://example.com/", Scope: "create"},
+			nil,
+		},
+		{"inactive", http.StatusOK, `{"active":false,"me":"https://example.com/"}`, nil, ErrInvalidToken},
+		{"no me", http.StatusOK, `{"scope":"create"}`, nil, ErrInvalidToken},
+		{"bad request", http.StatusBadRequest, `{"error":"invalid_request"}`, nil, ErrInvalidToken},
+		{"unauthorized", http.StatusUnauthorized, ``, nil, ErrInvalidToken},
+		{"forbidden", http.StatusForbidden, ``, nil, ErrInvalidToken},
+		{"not found", http.StatusNotFound, ``, nil, ErrInvalidToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("got authorization %q", got)
+				}
+				if got := r.Header.Get("Accept"); got != "application/json" {
+					t.Errorf("got accept %q", got)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			token, err := VerifyToken(server.URL, "secret")
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(token, test.want) {
+				t.Errorf("got %+v, want %+v", token, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusInternalServerError, ``},
+		{"not JSON", http.StatusOK, `<html></html>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			// Failures to verify aren't rejections, so the token isn't taken
+			// to be invalid.
+			if _, err := VerifyToken(server.URL, "secret"); err == nil || err == ErrInvalidToken {
+				t.Errorf("got error %v, want a failure other than ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestTokenHasScope(t *testing.T) {
+	token := &Token{Scope: "create  update media"}
+	for scope, want := range map[string]bool{"create": true, "update": true, "media": true, "delete": false, "": false} {
+		if got := token.HasScope(scope); got != want {
+			t.Errorf("%q: got %v, want %v", scope, got, want)
+		}
+	}
+}
+
+func TestSameProfile(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"https://example.com/", "https://example.com/", true},
+		{"https://example.com", "https://example.com/", true},
+		{"http://example.com/", "https://example.com", true},
+		{"example.com", "https://example.com/", true},
+		{"https://example.com/me", "https://example.com/me/", true},
+		{"https://example.com/", "https://example.org/", false},
+		{"https://example.com/", "https://example.com/me", false},
+		{"https://example.com/", "https://www.example.com/", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			if got := SameProfile(test.a, test.b); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codykrieger/microbridge/indieauth"
+	log "github.com/sirupsen/logrus"
+)
+
+// loginScope is the scope requested for tokens obtained through the login
+// flow: everything the bridge's XML-RPC methods can do.
+const loginScope = "create update delete undelete media"
+
+// pendingLoginTTL is how long a user has to approve a login at their
+// authorization endpoint.
+const pendingLoginTTL = 10 * time.Minute
+
+// LoginHandler serves the IndieAuth login flow, which gets a Micropub token
+// for the user's site and issues a bridge password for it, to be pasted into
+// the blog client in place of the token.
+type LoginHandler struct {
+	config    *Config
+	logins    *Logins
+	endpoints *EndpointCache
+
+	mu      sync.Mutex
+	pending map[string]*pendingLogin // state -> login
+}
+
+type pendingLogin struct {
+	request   *indieauth.AuthRequest
+	endpoints *indieauth.Endpoints
+	expires   time.Time
+}
+
+func NewLoginHandler(config *Config, logins *Logins, endpoints *EndpointCache) *LoginHandler {
+	return &LoginHandler{
+		config:    config,
+		logins:    logins,
+		endpoints: endpoints,
+		pending:   map[string]*pendingLogin{},
+	}
+}
+
+func (h *LoginHandler) clientID() string {
+	return h.config.BlogURL + "/"
+}
+
+func (h *LoginHandler) redirectURI() string {
+	return h.config.BlogURL + "/login/callback"
+}
+
+// HandleIndex shows the login form.
+func (h *LoginHandler) HandleIndex(w http.ResponseWriter, req *http.Request) {
+	h.render(w, http.StatusOK, loginPage{})
+}
+
+// HandleLogin discovers the endpoints of the site the user entered and sends
+// them to its authorization endpoint.
+func (h *LoginHandler) HandleLogin(w http.ResponseWriter, req *http.Request) {
+	me := strings.TrimSpace(req.FormValue("me"))
+	if me == "" {
+		h.render(w, http.StatusBadRequest, loginPage{Error: "Enter your site's URL."})
+		return
+	}
+	if !strings.Contains(me, "://") {
+		me = "https://" + me
+	}
+
+	endpoints, err := h.endpoints.Get(me)
+	if err != nil {
+		log.WithError(err).WithField("me", me).Error("login: discovery failed")
+		h.render(w, http.StatusBadRequest, loginPage{Me: me, Error: "Couldn't fetch " + me + "."})
+		return
+	}
+	if endpoints.Micropub == "" {
+		h.render(w, http.StatusBadRequest, loginPage{Me: me, Error: me + " doesn't advertise a Micropub endpoint."})
+		return
+	}
+
+	authReq, err := indieauth.NewAuthRequest(endpoints, h.clientID(), h.redirectURI(), loginScope)
+	if err != nil {
+		log.WithError(err).WithField("me", me).Error("login: can't start authorization")
+		h.render(w, http.StatusBadRequest, loginPage{Me: me, Error: me + " doesn't advertise IndieAuth endpoints."})
+		return
+	}
+
+	now := time.Now()
+
+	h.mu.Lock()
+	for state, p := range h.pending {
+		if now.After(p.expires) {
+			delete(h.pending, state)
+		}
+	}
+	h.pending[authReq.State] = &pendingLogin{
+		request:   authReq,
+		endpoints: endpoints,
+		expires:   now.Add(pendingLoginTTL),
+	}
+	h.mu.Unlock()
+
+	http.Redirect(w, req, authReq.URL(), http.StatusFound)
+}
+
+// HandleCallback redeems the authorization code the user was sent back with
+// and shows them their bridge password.
+func (h *LoginHandler) HandleCallback(w http.ResponseWriter, req *http.Request) {
+	state := req.FormValue("state")
+
+	h.mu.Lock()
+	p, ok := h.pending[state]
+	delete(h.pending, state)
+	h.mu.Unlock()
+
+	if !ok || time.Now().After(p.expires) {
+		h.render(w, http.StatusBadRequest, loginPage{Error: "That login has expired. Please try again."})
+		return
+	}
+
+	if e := req.FormValue("error"); e != "" {
+		log.WithField("error", e).Warn("login: authorization denied")
+		h.render(w, http.StatusForbidden, loginPage{Me: p.request.Me, Error: "Authorization was denied."})
+		return
+	}
+
+	token, err := p.request.Redeem(req.FormValue("code"))
+	if err != nil {
+		log.WithError(err).WithField("me", p.request.Me).Error("login: couldn't redeem authorization code")
+		h.render(w, http.StatusBadGateway, loginPage{Me: p.request.Me, Error: "Your token endpoint didn't issue a token."})
+		return
+	}
+
+	// The token endpoint may report a different profile URL than the one
+	// the user entered. That's only acceptable if it delegates to the same
+	// authorization endpoint.
+	me := p.request.Me
+	if token.Me != "" && !indieauth.SameProfile(token.Me, me) {
+		endpoints, err := h.endpoints.Get(token.Me)
+		if err != nil || endpoints.AuthorizationEndpoint != p.request.AuthorizationEndpoint {
+			log.WithFields(log.Fields{"me": me, "token_me": token.Me}).Error("login: token issued for another user")
+			h.render(w, http.StatusForbidden, loginPage{Me: me, Error: "Your token endpoint issued a token for another site."})
+			return
+		}
+		me = token.Me
+	}
+
+	password, err := indieauth.RandomString(24)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	account := Account{
+		Me:               me,
+		MicropubEndpoint: p.endpoints.Micropub,
+		MediaEndpoint:    p.endpoints.MediaEndpoint,
+		TokenEndpoint:    p.request.TokenEndpoint,
+		Token:            token.AccessToken,
+	}
+	if err := h.logins.Add(password, account); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.WithFields(log.Fields{"me": me, "scope": token.Scope}).Info("login: issued password")
+
+	h.render(w, http.StatusOK, loginPage{Me: me, Password: password, BlogURL: h.config.BlogURL})
+}
+
+type loginPage struct {
+	Me       string
+	Error    string
+	Password string
+	BlogURL  string
+}
+
+func (h *LoginHandler) render(w http.ResponseWriter, status int, page loginPage) {
+	if page.BlogURL == "" {
+		page.BlogURL = h.config.BlogURL
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := loginTemplate.Execute(w, page); err != nil {
+		log.WithError(err).Error("login: rendering failed")
+	}
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<title>microbridge</title>
+	<link rel="EditURI" type="application/rsd+xml" title="RSD" href="{{.BlogURL}}/xmlrpc.php?rsd" />
+</head>
+<body>
+	<h1>microbridge</h1>
+{{if .Password}}
+	<p>Signed in as <strong>{{.Me}}</strong>.</p>
+	<p>In your blog client, use <code>{{.BlogURL}}</code> as the blog address, any
+	username, and this password:</p>
+	<p><code>{{.Password}}</code></p>
+	<p>It won't be shown again.</p>
+{{else}}
+	{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+	<form action="{{.BlogURL}}/login" method="post">
+		<label for="me">Your site</label>
+		<input type="text" id="me" name="me" value="{{.Me}}" placeholder="https://example.com/" />
+		<button type="submit">Sign in</button>
+	</form>
+{{end}}
+</body>
+</html>
+`))
@@ -6,6 +6,9 @@ import (
 	// "io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/codykrieger/microbridge/xmlrpc"
@@ -20,7 +23,48 @@ type Config struct {
 	BlogURL  string
 	PostsURL string
 
+	// MicropubEndpoint is used for calls whose username doesn't name a site
+	// to discover the endpoint of.
 	MicropubEndpoint string
+
+	// DiscoverHostNames makes usernames that look like host names (e.g.
+	// example.com) name sites to discover endpoints from, as well as URLs.
+	DiscoverHostNames bool
+
+	// PageProperty and PageValue name the Micropub property (and its value)
+	// that marks an item as a page. Pages are only offered to clients if the
+	// server advertises a page post type, or if the convention was configured
+	// explicitly with PAGE_PROPERTY.
+	PageProperty string
+	PageValue    string
+	PagesEnabled bool
+
+	// TagPrefix, if set, marks the Micropub category values that are tags
+	// rather than categories (e.g. "#"). Without it, tags aren't supported:
+	// every value is a category, and clients can't send tags.
+	TagPrefix string
+
+	// Location is the blog's timezone. Dates are reported to clients in it,
+	// and dates clients send without a zone are taken to be in it.
+	Location *time.Location
+
+	// ScheduleMode selects how posts scheduled with post_status "future" are
+	// handled: scheduleLocal or scheduleUpstream.
+	ScheduleMode string
+
+	// TokenEndpoint, if set, is the IndieAuth token endpoint tokens are
+	// verified against. TokenMe, if set, is the profile URL tokens must have
+	// been issued for.
+	TokenEndpoint string
+	TokenMe       string
+
+	// AuthCacheTTL is how long a token the Micropub server accepted is
+	// trusted before it's checked again.
+	AuthCacheTTL time.Duration
+
+	// DataDir is where the bridge keeps its local state, such as the
+	// registry of WordPress IDs.
+	DataDir string
 }
 
 var config = &Config{}
@@ -45,23 +89,115 @@ func init() {
 	if config.MicropubEndpoint == "" {
 		config.MicropubEndpoint = "https://micro.blog/micropub"
 	}
+
+	if v := os.Getenv("DISCOVER_HOST_NAMES"); v != "" {
+		discover, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("DISCOVER_HOST_NAMES: %v", err)
+		}
+		config.DiscoverHostNames = discover
+	}
+
+	config.PageProperty, config.PageValue = "post-type", "page"
+	if v := os.Getenv("PAGE_PROPERTY"); v != "" {
+		toks := strings.SplitN(v, "=", 2)
+		if len(toks) != 2 || toks[0] == "" || toks[1] == "" {
+			fatalf("PAGE_PROPERTY must be of the form name=value (e.g. mp-channel=pages)")
+		}
+		config.PageProperty, config.PageValue = toks[0], toks[1]
+		config.PagesEnabled = true
+	}
+
+	config.TagPrefix = os.Getenv("TAG_PREFIX")
+
+	config.Location = time.Local
+	if v := os.Getenv("BLOG_TIMEZONE"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			fatalf("BLOG_TIMEZONE: %v", err)
+		}
+		config.Location = loc
+	}
+
+	config.ScheduleMode = os.Getenv("SCHEDULE_MODE")
+	switch config.ScheduleMode {
+	case "":
+		config.ScheduleMode = scheduleLocal
+	case scheduleLocal, scheduleUpstream:
+	default:
+		fatalf("SCHEDULE_MODE must be %s or %s", scheduleLocal, scheduleUpstream)
+	}
+
+	config.TokenEndpoint = os.Getenv("TOKEN_ENDPOINT")
+	config.TokenMe = os.Getenv("TOKEN_ME")
+
+	config.AuthCacheTTL = 5 * time.Minute
+	if v := os.Getenv("AUTH_CACHE_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			fatalf("AUTH_CACHE_TTL: %v", err)
+		}
+		config.AuthCacheTTL = ttl
+	}
+
+	config.DataDir = os.Getenv("DATA_DIR")
+	if config.DataDir == "" {
+		config.DataDir = "data"
+	}
 }
 
 func main() {
 	router := mux.NewRouter()
 
-	srv := &WPService{config: config}
+	ids, err := OpenRegistry(filepath.Join(config.DataDir, "ids.json"))
+	if err != nil {
+		fatalf("OpenRegistry: %v", err)
+	}
+
+	schedule, err := OpenSchedule(filepath.Join(config.DataDir, "schedule.json"))
+	if err != nil {
+		fatalf("OpenSchedule: %v", err)
+	}
+
+	logins, err := OpenLogins(filepath.Join(config.DataDir, "logins.json"))
+	if err != nil {
+		fatalf("OpenLogins: %v", err)
+	}
+
+	uploads, err := OpenUploads(filepath.Join(config.DataDir, "uploads.json"))
+	if err != nil {
+		fatalf("OpenUploads: %v", err)
+	}
+
+	srv := &WPService{
+		config:    config,
+		ids:       ids,
+		schedule:  schedule,
+		creds:     NewCredentialCache(config.AuthCacheTTL),
+		logins:    logins,
+		endpoints: NewEndpointCache(),
+		uploads:   uploads,
+	}
+	go srv.runScheduler(time.Minute)
 
 	codec := xmlrpc.NewCodec()
 	codec.AutoCapitalizeMethodName = true
+	codec.MapError = faultFromError
+	codec.Location = config.Location
 
 	rs := rpc.NewServer()
 	rs.RegisterCodec(codec, "text/xml")
-	rs.RegisterService(srv, "wp")
-	rs.RegisterService(srv, "metaWeblog")
+	system := xmlrpc.NewSystemService(rs)
+	system.RegisterService(srv, "wp")
+	system.RegisterService(&MetaWeblogService{wp: srv}, "metaWeblog")
+	system.RegisterService(&BloggerService{wp: srv}, "blogger")
+	system.RegisterService(system, "system")
 
-	router.HandleFunc("/", handleIndex).Methods(http.MethodGet)
-	router.HandleFunc("/xmlrpc.php", handleRsd)
+	login := NewLoginHandler(config, logins, srv.endpoints)
+	router.HandleFunc("/", login.HandleIndex).Methods(http.MethodGet)
+	router.HandleFunc("/login", login.HandleLogin).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/login/callback", login.HandleCallback).Methods(http.MethodGet)
+	router.HandleFunc("/xmlrpc.php", srv.handleRsd)
 	router.Handle("/xmlrpc", rs).Methods(http.MethodPost)
 
 	handler := logHandler(
@@ -131,26 +267,23 @@ func logHandler(next http.Handler) http.Handler {
 	})
 }
 
-func handleIndex(w http.ResponseWriter, req *http.Request) {
-	fmt.Fprintf(w, `<!DOCTYPE html>
-<html>
-<head>
-	<title>sup</title>
-	<link rel="EditURI" type="application/rsd+xml" title="RSD" href="%s/xmlrpc.php?rsd" />
-</head>
-<body>
-	<h1>nothing to see here...</h1>
-	<p>move along</p>
-</body>
-</html>`, config.BlogURL)
-}
-
-func handleRsd(w http.ResponseWriter, req *http.Request) {
-	if req.URL.RawQuery != "rsd" {
+// handleRsd serves the RSD document clients discover the XML-RPC endpoint
+// with. Each blog (Micropub destination) has its own, selected with the blog
+// parameter (e.g. /xmlrpc.php?rsd&blog=2) and listing its blog ID; without
+// it, the blog ID is left empty and clients pick one from wp.getUsersBlogs.
+func (s *WPService) handleRsd(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if _, ok := query["rsd"]; !ok {
 		w.WriteHeader(http.StatusNotImplemented)
 		return
 	}
 
+	blogID := query.Get("blog")
+	if _, ok := s.ids.Key(kindBlog, blogID); blogID != "" && !ok {
+		http.NotFound(w, req)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
 	fmt.Fprintf(w, `<rsd xmlns="http://archipelago.phrasewise.com/rsd" version="1.0">
 <service>
@@ -158,8 +291,8 @@ func handleRsd(w http.ResponseWriter, req *http.Request) {
 	<engineLink>https://wordpress.org/</engineLink>
 	<homePageLink>%s</homePageLink>
 	<apis>
-		<api name="WordPress" blogID="1" preferred="true" apiLink="%s/xmlrpc"/>
+		<api name="WordPress" blogID="%s" preferred="true" apiLink="%s/xmlrpc"/>
 	</apis>
 	</service>
-</rsd>`, config.BlogURL, config.BlogURL)
+</rsd>`, s.config.BlogURL, blogID, s.config.BlogURL)
 }
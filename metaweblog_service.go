@@ -0,0 +1,287 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/codykrieger/microbridge/xmlrpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// moreTag separates the teaser of a post from the rest of its content, which
+// MetaWeblog clients edit separately as mt_text_more.
+const moreTag = "<!--more-->"
+
+// MetaWeblogService implements the MetaWeblog API. Its methods take and
+// return MetaWeblog structs rather than WordPress posts, so they can't be
+// served by WPService directly.
+type MetaWeblogService struct {
+	wp *WPService
+}
+
+// Help implements xmlrpc.Helper.
+func (s *MetaWeblogService) Help(method string) string {
+	switch method {
+	case "GetRecentPosts":
+		return "Returns the most recent posts."
+	case "GetPost":
+		return "Returns a single post."
+	case "NewPost":
+		return "Creates a post with a Micropub create request and returns its ID. Posts are drafts unless publish is set or post_status says otherwise."
+	case "EditPost":
+		return "Updates a post with a Micropub update request."
+	case "GetCategories":
+		return "Returns the categories known to the Micropub server."
+	case "NewMediaObject":
+		return "Uploads a file to the Micropub media endpoint and returns its URL."
+	}
+	return ""
+}
+
+// postContent translates a MetaWeblog struct into the equivalent
+// wp.newPost/wp.editPost content. The publish flag only applies if the client
+// didn't send a post status.
+func (c *MetaWeblogContent) postContent(publish bool) *PostContent {
+	content := &PostContent{
+		Title:     c.Title,
+		Content:   c.Description,
+		Excerpt:   c.Excerpt,
+		Date:      c.DateCreated,
+		DateGMT:   c.DateCreatedGMT,
+		Status:    c.Status,
+		Name:      c.Slug,
+		Keywords:  c.Keywords,
+		Enclosure: c.Enclosure,
+	}
+
+	if c.Description != nil && c.TextMore != nil && *c.TextMore != "" {
+		description := *c.Description + "\n" + moreTag + "\n" + *c.TextMore
+		content.Content = &description
+	}
+
+	if c.Categories != nil {
+		content.TermsNames = &PostTerms{Category: c.Categories}
+	}
+
+	if content.Status == nil {
+		status := "draft"
+		if publish {
+			status = "publish"
+		}
+		content.Status = &status
+	}
+
+	return content
+}
+
+// metaWeblogFromPost translates a WordPress post into a MetaWeblog struct.
+func metaWeblogFromPost(post *Post) MetaWeblog {
+	description, more := post.Content, ""
+	if i := strings.Index(description, moreTag); i != -1 {
+		description, more = description[:i], description[i+len(moreTag):]
+		description, more = strings.TrimSuffix(description, "\n"), strings.TrimPrefix(more, "\n")
+	}
+
+	categories, tags := []string{}, []string{}
+	for _, term := range post.Terms {
+		if term.Taxonomy == taxonomyPostTag {
+			tags = append(tags, term.Name)
+		} else {
+			categories = append(categories, term.Name)
+		}
+	}
+
+	return MetaWeblog{
+		PostID:         post.PostID,
+		Title:          post.Title,
+		Description:    description,
+		DateCreated:    post.Date,
+		DateCreatedGMT: post.DateGMT,
+		Categories:     categories,
+		Keywords:       strings.Join(tags, ", "),
+		Status:         post.Status,
+		Excerpt:        post.Excerpt,
+		TextMore:       more,
+		Slug:           post.Name,
+		Link:           post.Link,
+		PermaLink:      post.Link,
+		UserID:         post.Author,
+		Enclosure:      post.Enclosure,
+	}
+}
+
+type MetaWeblogGetRecentPostsArgs struct {
+	BlogID        string
+	Username      string
+	Password      string
+	NumberOfPosts int
+}
+
+type MetaWeblogGetRecentPostsReply struct {
+	Posts []MetaWeblog
+}
+
+func (s *MetaWeblogService) GetRecentPosts(req *http.Request, args *MetaWeblogGetRecentPostsArgs, reply *MetaWeblogGetRecentPostsReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"n":   args.NumberOfPosts,
+	}).Info("---> metaWeblog.GetRecentPosts")
+
+	client, err := s.wp.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	hasPages, err := s.wp.pagesSupported(client)
+	if err != nil {
+		return err
+	}
+
+	filter := &PostFilter{PostType: "post", Number: args.NumberOfPosts}
+
+	posts, err := s.wp.listPosts(client, sourceQuery(filter, hasPages))
+	if err != nil {
+		return err
+	}
+
+	reply.Posts = []MetaWeblog{}
+	for _, post := range filterPosts(posts, filter) {
+		reply.Posts = append(reply.Posts, metaWeblogFromPost(&post))
+	}
+
+	return nil
+}
+
+type MetaWeblogGetPostArgs struct {
+	PostID   string
+	Username string
+	Password string
+}
+
+type MetaWeblogGetPostReply struct {
+	Post MetaWeblog
+}
+
+func (s *MetaWeblogService) GetPost(req *http.Request, args *MetaWeblogGetPostArgs, reply *MetaWeblogGetPostReply) error {
+	log.WithFields(log.Fields{
+		"u":   args.Username,
+		"pid": args.PostID,
+	}).Info("---> metaWeblog.GetPost")
+
+	client, err := s.wp.checkPostAuth(args.PostID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.wp.findPost(client, args.PostID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return xmlrpc.ErrNotFound
+	}
+
+	split := s.wp.termSplitter()
+
+	post, err := s.wp.postFromItem(args.PostID, item, s.wp.postType(item), split)
+	if err != nil {
+		return err
+	}
+
+	reply.Post = metaWeblogFromPost(&post)
+
+	return nil
+}
+
+type MetaWeblogNewPostArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Content  MetaWeblogContent
+	Publish  bool
+}
+
+type MetaWeblogNewPostReply struct {
+	PostID string
+}
+
+func (s *MetaWeblogService) NewPost(req *http.Request, args *MetaWeblogNewPostArgs, reply *MetaWeblogNewPostReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+	}).Info("---> metaWeblog.NewPost")
+
+	client, err := s.wp.checkAuth(args.BlogID, args.Username, args.Password, scopeCreate)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.wp.newPost(client, args.Content.postContent(args.Publish))
+	if err != nil {
+		return err
+	}
+
+	reply.PostID = id
+
+	return nil
+}
+
+type MetaWeblogEditPostArgs struct {
+	PostID   string
+	Username string
+	Password string
+	Content  MetaWeblogContent
+	Publish  bool
+}
+
+type MetaWeblogEditPostReply struct {
+	Success bool
+}
+
+func (s *MetaWeblogService) EditPost(req *http.Request, args *MetaWeblogEditPostArgs, reply *MetaWeblogEditPostReply) error {
+	log.WithFields(log.Fields{
+		"u":   args.Username,
+		"pid": args.PostID,
+	}).Info("---> metaWeblog.EditPost")
+
+	content := args.Content.postContent(args.Publish)
+
+	client, err := s.wp.checkPostAuth(args.PostID, args.Username, args.Password, editScope(content))
+	if err != nil {
+		return err
+	}
+
+	if err := s.wp.editPost(client, args.PostID, content); err != nil {
+		return err
+	}
+
+	reply.Success = true
+
+	return nil
+}
+
+func (s *MetaWeblogService) GetCategories(req *http.Request, args *GetCategoriesArgs, reply *GetCategoriesReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+	}).Info("---> metaWeblog.GetCategories")
+
+	client, err := s.wp.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	categories, err := s.wp.categoryList(client)
+	if err != nil {
+		return err
+	}
+
+	reply.Categories = categories
+
+	return nil
+}
+
+func (s *MetaWeblogService) NewMediaObject(req *http.Request, args *NewMediaObjectArgs, reply *NewMediaObjectReply) error {
+	return s.wp.NewMediaObject(req, args, reply)
+}
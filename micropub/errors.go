@@ -0,0 +1,112 @@
+package micropub
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Error codes defined by the Micropub spec, borrowed from OAuth 2.0.
+const (
+	ErrorForbidden         = "forbidden"
+	ErrorUnauthorized      = "unauthorized"
+	ErrorInsufficientScope = "insufficient_scope"
+	ErrorInvalidRequest    = "invalid_request"
+)
+
+// Error is an unsuccessful response from a Micropub server.
+type Error struct {
+	// StatusCode and Status are taken from the HTTP response.
+	StatusCode int
+	Status     string
+	// Code is the error code from the response body (e.g.
+	// ErrorInsufficientScope), if any.
+	Code string `json:"error"`
+	// Description is the human-readable error_description, if any.
+	Description string `json:"error_description"`
+	// Scope is the scope the request required, if the server said.
+	Scope string `json:"scope"`
+}
+
+func (e *Error) Error() string {
+	msg := "micropub: " + e.Status
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// IsAuthError reports whether err is a Micropub error saying the token was
+// missing, invalid or expired, or isn't allowed to do what was asked.
+func IsAuthError(err error) bool {
+	e, ok := err.(*Error)
+	return ok && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+// newError reads the error payload from an unsuccessful response and reports
+// it to the client's OnError hook.
+func (c *Client) newError(resp *http.Response) *Error {
+	e := newError(resp)
+	if c.OnError != nil {
+		c.OnError(e)
+	}
+	return e
+}
+
+// newError reads the error payload from an unsuccessful response. Servers
+// that don't send a JSON payload still produce an Error carrying the HTTP
+// status, and a code derived from it where one applies.
+func newError(resp *http.Response) *Error {
+	e := &Error{StatusCode: resp.StatusCode, Status: resp.Status}
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	json.Unmarshal(data, e)
+
+	// OAuth servers may also describe the error in the WWW-Authenticate
+	// header, e.g. `Bearer error="insufficient_scope", scope="create"`.
+	params := parseAuthenticateHeader(resp.Header.Get("WWW-Authenticate"))
+	if e.Code == "" {
+		e.Code = params["error"]
+	}
+	if e.Description == "" {
+		e.Description = params["error_description"]
+	}
+	if e.Scope == "" {
+		e.Scope = params["scope"]
+	}
+
+	if e.Code == "" {
+		switch resp.StatusCode {
+		case http.StatusBadRequest:
+			e.Code = ErrorInvalidRequest
+		case http.StatusUnauthorized:
+			e.Code = ErrorUnauthorized
+		case http.StatusForbidden:
+			e.Code = ErrorForbidden
+		}
+	}
+
+	return e
+}
+
+func parseAuthenticateHeader(header string) map[string]string {
+	params := map[string]string{}
+
+	if i := strings.Index(header, " "); i != -1 {
+		header = header[i+1:]
+	}
+
+	for _, param := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+
+	return params
+}
@@ -0,0 +1,140 @@
+package micropub
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNewError(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		authenticate string
+		body         string
+		want         Error
+	}{
+		{
+			"json payload",
+			http.StatusBadRequest,
+			"",
+			`{"error":"invalid_request","error_description":"missing url"}`,
+			Error{StatusCode: 400, Status: "400 Bad Request", Code: ErrorInvalidRequest, Description: "missing url"},
+		},
+		{
+			"scope in payload",
+			http.StatusUnauthorized,
+			"",
+			`{"error":"insufficient_scope","scope":"create"}`,
+			Error{StatusCode: 401, Status: "401 Unauthorized", Code: ErrorInsufficientScope, Scope: "create"},
+		},
+		{
+			"www-authenticate",
+			http.StatusUnauthorized,
+			`Bearer realm="example", error="insufficient_scope", error_description="needs create", scope="create"`,
+			"",
+			Error{StatusCode: 401, Status: "401 Unauthorized", Code: ErrorInsufficientScope, Description: "needs create", Scope: "create"},
+		},
+		{
+			"payload before www-authenticate",
+			http.StatusForbidden,
+			`Bearer error="invalid_token", scope="update"`,
+			`{"error":"forbidden"}`,
+			Error{StatusCode: 403, Status: "403 Forbidden", Code: ErrorForbidden, Scope: "update"},
+		},
+		{
+			"html body",
+			http.StatusUnauthorized,
+			"",
+			`<html><body>Unauthorized</body></html>`,
+			Error{StatusCode: 401, Status: "401 Unauthorized", Code: ErrorUnauthorized},
+		},
+		{"no body, bad request", http.StatusBadRequest, "", "", Error{StatusCode: 400, Status: "400 Bad Request", Code: ErrorInvalidRequest}},
+		{"no body, forbidden", http.StatusForbidden, "", "", Error{StatusCode: 403, Status: "403 Forbidden", Code: ErrorForbidden}},
+		{"no body, server error", http.StatusInternalServerError, "", "", Error{StatusCode: 500, Status: "500 Internal Server Error"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.authenticate != "" {
+					w.Header().Set("WWW-Authenticate", test.authenticate)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			var reported *Error
+			client := NewClient(server.URL, "token")
+			client.OnError = func(e *Error) { reported = e }
+
+			_, err := client.GetConfig()
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("got error %v, want an *Error", err)
+			}
+			if *e != test.want {
+				t.Errorf("got %+v, want %+v", *e, test.want)
+			}
+			if reported != e {
+				t.Errorf("OnError got %v, want the returned error", reported)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	e := &Error{StatusCode: 400, Status: "400 Bad Request", Code: ErrorInvalidRequest, Description: "missing url"}
+	if got, want := e.Error(), "micropub: 400 Bad Request: invalid_request: missing url"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseAuthenticateHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]string
+	}{
+		{"", map[string]string{}},
+		{"Bearer", map[string]string{}},
+		{`Bearer error="invalid_token"`, map[string]string{"error": "invalid_token"}},
+		{
+			`Bearer realm="example",error="insufficient_scope", scope="create update"`,
+			map[string]string{"realm": "example", "error": "insufficient_scope", "scope": "create update"},
+		},
+		{`Bearer error=invalid_token`, map[string]string{"error": "invalid_token"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			if got := parseAuthenticateHeader(test.header); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unauthorized", &Error{StatusCode: http.StatusUnauthorized}, true},
+		{"forbidden", &Error{StatusCode: http.StatusForbidden}, true},
+		{"bad request", &Error{StatusCode: http.StatusBadRequest}, false},
+		{"other error", errors.New("connection refused"), false},
+		{"nil", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsAuthError(test.err); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -3,34 +3,111 @@ package micropub
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
-type HTTPError struct {
-	resp *http.Response
+type Item struct {
+	Type       string         `json:"type"`
+	Properties ItemProperties `json:"properties"`
 }
 
-func (e *HTTPError) Error() string {
-	return e.resp.Status
+type ItemProperties struct {
+	Name       []string `json:"name"`
+	Content    []string `json:"content"`
+	Category   []string `json:"category"`
+	Photo      []string `json:"photo"`
+	PostStatus []string `json:"post-status"`
+	Published  []string `json:"published"`
+	UID        []uint64 `json:"uid"`
+	URL        []string `json:"url"`
+
+	// Raw holds every property of the item, including those without a field
+	// above.
+	Raw map[string]json.RawMessage `json:"-"`
 }
 
-type Item struct {
-	Type       string `json:"type"`
-	Properties struct {
-		Name       []string `json:"name"`
-		Content    []string `json:"content"`
-		Photo      []string `json:"photo"`
-		PostStatus []string `json:"post-status"`
-		Published  []string `json:"published"`
-		UID        []uint64 `json:"uid"`
-		URL        []string `json:"url"`
-	} `json:"properties"`
+func (p *ItemProperties) UnmarshalJSON(data []byte) error {
+	type plain ItemProperties
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &p.Raw)
+}
+
+// Strings returns the string values of the named property. A bare string is
+// treated as a single value, and values that aren't strings are skipped.
+func (p *ItemProperties) Strings(name string) []string {
+	raw, ok := p.Raw[name]
+	if !ok {
+		return nil
+	}
+
+	var values []interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil
+		}
+		values = []interface{}{value}
+	}
+
+	strs := []string{}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// SameURL reports whether two item URLs refer to the same item. Micro.blog
+// reports item URLs with http:// even when the Location it returns for a new
+// item uses https://, so the scheme is ignored.
+func SameURL(a, b string) bool {
+	return StripScheme(a) == StripScheme(b)
 }
 
+// StripScheme returns a URL without its scheme (e.g. "example.com/a" for
+// "https://example.com/a").
+func StripScheme(u string) string {
+	if i := strings.Index(u, "://"); i != -1 {
+		return u[i+3:]
+	}
+	return u
+}
+
+// Properties holds the microformats2 properties of an item. Every property is
+// multi-valued.
+type Properties map[string][]interface{}
+
+// Encoding selects how the bodies of write requests are encoded.
+type Encoding int
+
+const (
+	// EncodingJSON sends write requests as application/json.
+	EncodingJSON Encoding = iota
+	// EncodingForm sends write requests as application/x-www-form-urlencoded.
+	// Updates can't be form-encoded and are always sent as JSON.
+	EncodingForm
+)
+
 type Client struct {
 	Endpoint string
 	Token    string
+	Encoding Encoding
+
+	// Destination, if set, is the uid of the destination (e.g. one of the
+	// blogs of a Micro.blog account, as listed in the config) that queries
+	// and writes apply to. It's sent as mp-destination.
+	Destination string
+
+	// OnError, if set, is called with every error response from the server,
+	// e.g. to forget a token the server no longer accepts.
+	OnError func(*Error)
 }
 
 func NewClient(endpoint, token string) *Client {
@@ -62,38 +139,130 @@ func (c *Client) GetCategories() ([]string, error) {
 	var resp struct {
 		Categories []string `json:"categories"`
 	}
-	if err := c.get("?q=category", &resp); err != nil {
+	if err := c.get("?"+c.query(url.Values{"q": {"category"}}), &resp); err != nil {
 		return nil, err
 	}
 	return resp.Categories, nil
 }
 
-func (c *Client) GetPosts() ([]*Item, error) {
+// SourceQuery narrows down the items returned by GetPosts. Servers are free
+// to ignore any of these, so callers should be prepared to receive more items
+// than they asked for.
+type SourceQuery struct {
+	Limit      int
+	Offset     int
+	PostStatus string
+}
+
+// params returns the parameters of a q=source query.
+func (q *SourceQuery) params() url.Values {
+	params := url.Values{"q": {"source"}}
+	if q != nil {
+		if q.Limit > 0 {
+			params.Set("limit", strconv.Itoa(q.Limit))
+		}
+		if q.Offset > 0 {
+			params.Set("offset", strconv.Itoa(q.Offset))
+		}
+		if q.PostStatus != "" {
+			params.Set("post-status", q.PostStatus)
+		}
+	}
+	return params
+}
+
+func (c *Client) GetPosts(query *SourceQuery) ([]*Item, error) {
+	params := query.params()
+
 	var resp struct {
 		Items []*Item `json:"items"`
 	}
-	if err := c.get("?q=source", &resp); err != nil {
+	if err := c.get("?"+c.query(params), &resp); err != nil {
 		return nil, err
 	}
 	return resp.Items, nil
 }
 
+// GetPost returns the item at the given URL, or nil if there is no such item.
+//
+// Servers that ignore the url filter (such as Micro.blog) respond with the
+// full list of items, in which case the list is searched instead.
+func (c *Client) GetPost(itemURL string) (*Item, error) {
+	var resp struct {
+		Properties *ItemProperties `json:"properties"`
+		Items      []*Item         `json:"items"`
+	}
+	if err := c.get("?"+c.query(url.Values{"q": {"source"}, "url": {itemURL}}), &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Properties != nil {
+		item := &Item{Type: "h-entry", Properties: *resp.Properties}
+		if len(item.Properties.URL) == 0 {
+			item.Properties.URL = []string{itemURL}
+		}
+		return item, nil
+	}
+
+	for _, item := range resp.Items {
+		if len(item.Properties.URL) > 0 && SameURL(item.Properties.URL[0], itemURL) {
+			return item, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// MediaItem is a file listed by a media endpoint.
+type MediaItem struct {
+	URL       string `json:"url"`
+	Published string `json:"published"`
+	Alt       string `json:"alt"`
+}
+
+// GetMedia returns the files uploaded to the given media endpoint, newest
+// first. Listing files is an extension of the Micropub spec (q=source on the
+// media endpoint, as implemented by Micro.blog), so servers without it respond
+// with an error.
+func (c *Client) GetMedia(mediaEndpoint string, query *SourceQuery) ([]*MediaItem, error) {
+	var resp struct {
+		Items []*MediaItem `json:"items"`
+	}
+	if err := c.getFrom(mediaEndpoint, "?"+c.query(query.params()), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// query encodes the parameters of a query, adding the client's destination.
+func (c *Client) query(params url.Values) string {
+	if c.Destination != "" {
+		params.Set("mp-destination", c.Destination)
+	}
+	return params.Encode()
+}
+
 func (c *Client) get(path string, dest interface{}) error {
+	return c.getFrom(c.Endpoint, path, dest)
+}
+
+// getFrom is like get, for endpoints other than the Micropub endpoint.
+func (c *Client) getFrom(endpoint, path string, dest interface{}) error {
 	h := &http.Client{}
 
-	log.Info("micropub: GET /micropub" + path)
+	log.Info("micropub: GET " + endpoint + path)
 
-	// FIXME: URL-encode path
-	req, _ := http.NewRequest(http.MethodGet, c.Endpoint+path, nil)
+	req, _ := http.NewRequest(http.MethodGet, endpoint+path, nil)
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
 	resp, err := h.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return &HTTPError{resp: resp}
+		return c.newError(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
@@ -0,0 +1,277 @@
+package micropub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Result describes the server's response to a write request.
+type Result struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// URL is the URL of the created item or uploaded file, taken from the
+	// Location header of the response.
+	URL string
+	// Error and ErrorDescription hold the error payload of an unsuccessful
+	// response, if the server sent one. The same information is available
+	// from the *Error returned alongside the result.
+	Error            string
+	ErrorDescription string
+}
+
+// Create creates a new h-entry with the given properties. The URL of the new
+// item is returned in the result.
+func (c *Client) Create(properties Properties) (*Result, error) {
+	var result *Result
+	var err error
+
+	if c.Destination != "" {
+		withDestination := Properties{"mp-destination": {c.Destination}}
+		for name, values := range properties {
+			withDestination[name] = values
+		}
+		properties = withDestination
+	}
+
+	if c.Encoding == EncodingForm {
+		form := url.Values{"h": {"entry"}}
+		if err := addFormProperties(form, properties); err != nil {
+			return nil, err
+		}
+		result, err = c.postForm(form)
+	} else {
+		result, err = c.postJSON(map[string]interface{}{
+			"type":       []string{"h-entry"},
+			"properties": properties,
+		})
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if result.URL == "" {
+		return result, fmt.Errorf("micropub: create response is missing a Location header")
+	}
+
+	return result, nil
+}
+
+// Update describes the changes made by a Micropub update request.
+type Update struct {
+	// Replace replaces all values of each property.
+	Replace Properties
+	// Add adds values to each property.
+	Add Properties
+	// Delete removes the given values from each property.
+	Delete Properties
+	// DeleteProperties removes each property entirely.
+	DeleteProperties []string
+}
+
+// Empty reports whether the update makes no changes.
+func (u *Update) Empty() bool {
+	return len(u.Replace) == 0 && len(u.Add) == 0 && len(u.Delete) == 0 && len(u.DeleteProperties) == 0
+}
+
+// Update applies the given changes to the item at itemURL.
+//
+// Micropub can't express removing values and removing whole properties in the
+// same request, so if the update does both, two requests are made and the
+// result of the second is returned.
+func (c *Client) Update(itemURL string, update *Update) (*Result, error) {
+	body := c.actionBody("update", itemURL)
+	if len(update.Replace) > 0 {
+		body["replace"] = update.Replace
+	}
+	if len(update.Add) > 0 {
+		body["add"] = update.Add
+	}
+	if len(update.Delete) > 0 {
+		body["delete"] = update.Delete
+	} else if len(update.DeleteProperties) > 0 {
+		body["delete"] = update.DeleteProperties
+	}
+
+	result, err := c.postJSON(body)
+	if err != nil {
+		return result, err
+	}
+
+	if len(update.Delete) > 0 && len(update.DeleteProperties) > 0 {
+		body := c.actionBody("update", itemURL)
+		body["delete"] = update.DeleteProperties
+		return c.postJSON(body)
+	}
+
+	return result, nil
+}
+
+// Delete deletes the item at itemURL.
+func (c *Client) Delete(itemURL string) (*Result, error) {
+	return c.action("delete", itemURL)
+}
+
+// Undelete restores the previously deleted item at itemURL.
+func (c *Client) Undelete(itemURL string) (*Result, error) {
+	return c.action("undelete", itemURL)
+}
+
+// UploadMedia uploads a file to the given media endpoint. The URL of the
+// uploaded file is returned in the result.
+func (c *Client) UploadMedia(mediaEndpoint, filename, contentType string, data io.Reader) (*Result, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	if c.Destination != "" {
+		if err := w.WriteField("mp-destination", c.Destination); err != nil {
+			return nil, err
+		}
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, escapeQuotes(filename)))
+	header.Set("Content-Type", contentType)
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("micropub: POST %s (%s; %d bytes)", mediaEndpoint, contentType, body.Len())
+
+	result, err := c.post(mediaEndpoint, w.FormDataContentType(), &body)
+	if err != nil {
+		return result, err
+	}
+
+	if result.URL == "" {
+		return result, fmt.Errorf("micropub: media upload response is missing a Location header")
+	}
+
+	return result, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func (c *Client) action(action, itemURL string) (*Result, error) {
+	if c.Encoding == EncodingForm {
+		form := url.Values{"action": {action}, "url": {itemURL}}
+		if c.Destination != "" {
+			form.Set("mp-destination", c.Destination)
+		}
+		return c.postForm(form)
+	}
+	return c.postJSON(c.actionBody(action, itemURL))
+}
+
+// actionBody returns the JSON body of an action on the item at itemURL.
+func (c *Client) actionBody(action, itemURL string) map[string]interface{} {
+	body := map[string]interface{}{
+		"action": action,
+		"url":    itemURL,
+	}
+	if c.Destination != "" {
+		body["mp-destination"] = c.Destination
+	}
+	return body
+}
+
+// addFormProperties adds properties to form using the form-encoded Micropub
+// syntax, in which multi-valued properties are sent as "name[]".
+func addFormProperties(form url.Values, properties Properties) error {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := properties[name]
+		key := name
+		if len(values) > 1 {
+			key += "[]"
+		}
+
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				form.Add(key, v)
+			case bool, int, int64, uint64, float64:
+				form.Add(key, fmt.Sprint(v))
+			default:
+				return fmt.Errorf("micropub: property '%s' can't be form-encoded", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) postJSON(body interface{}) (*Result, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("micropub: POST /micropub (json)")
+
+	return c.post(c.Endpoint, "application/json", bytes.NewReader(data))
+}
+
+func (c *Client) postForm(form url.Values) (*Result, error) {
+	log.Info("micropub: POST /micropub (form)")
+
+	return c.post(c.Endpoint, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+func (c *Client) post(endpoint, contentType string, body io.Reader) (*Result, error) {
+	h := &http.Client{}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := h.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &Result{
+		StatusCode: resp.StatusCode,
+		URL:        resp.Header.Get("Location"),
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return result, nil
+	}
+
+	e := c.newError(resp)
+	result.Error = e.Code
+	result.ErrorDescription = e.Description
+
+	return result, e
+}
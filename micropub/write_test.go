@@ -0,0 +1,313 @@
+package micropub
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// request is a write request received by a test server.
+type request struct {
+	ContentType   string
+	Authorization string
+	Body          string
+}
+
+// newWriteServer returns a Micropub server that records the requests it
+// receives and answers them with the given Location header.
+func newWriteServer(t *testing.T, location string) (*httptest.Server, *[]request) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, request{
+			ContentType:   r.Header.Get("Content-Type"),
+			Authorization: r.Header.Get("Authorization"),
+			Body:          string(body),
+		})
+		if location != "" {
+			w.Header().Set("Location", location)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// jsonBody decodes the JSON body of a recorded request.
+func jsonBody(t *testing.T, r request) map[string]interface{} {
+	if r.ContentType != "application/json" {
+		t.Fatalf("got content type %q, want application/json", r.ContentType)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// formBody decodes the form-encoded body of a recorded request.
+func formBody(t *testing.T, r request) url.Values {
+	if r.ContentType != "application/x-www-form-urlencoded" {
+		t.Fatalf("got content type %q, want application/x-www-form-urlencoded", r.ContentType)
+	}
+	form, err := url.ParseQuery(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+func TestCreateJSON(t *testing.T) {
+	server, requests := newWriteServer(t, "https://example.com/1")
+	client := NewClient(server.URL, "token")
+	client.Destination = "https://example.com/"
+
+	result, err := client.Create(Properties{"content": {"Hello"}, "category": {"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.URL != "https://example.com/1" || result.StatusCode != http.StatusCreated {
+		t.Errorf("got result %+v", result)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*requests))
+	}
+	r := (*requests)[0]
+	if r.Authorization != "Bearer token" {
+		t.Errorf("got authorization %q", r.Authorization)
+	}
+	want := map[string]interface{}{
+		"type": []interface{}{"h-entry"},
+		"properties": map[string]interface{}{
+			"content":        []interface{}{"Hello"},
+			"category":       []interface{}{"a", "b"},
+			"mp-destination": []interface{}{"https://example.com/"},
+		},
+	}
+	if got := jsonBody(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("got body %v, want %v", got, want)
+	}
+}
+
+func TestCreateForm(t *testing.T) {
+	server, requests := newWriteServer(t, "https://example.com/1")
+	client := NewClient(server.URL, "token")
+	client.Encoding = EncodingForm
+
+	if _, err := client.Create(Properties{"content": {"Hello"}, "category": {"a", "b"}, "photo": {"https://example.com/a.jpg"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := url.Values{
+		"h":          {"entry"},
+		"content":    {"Hello"},
+		"category[]": {"a", "b"},
+		"photo":      {"https://example.com/a.jpg"},
+	}
+	if got := formBody(t, (*requests)[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("got body %v, want %v", got, want)
+	}
+}
+
+func TestCreateFormRefusesNestedValues(t *testing.T) {
+	server, requests := newWriteServer(t, "https://example.com/1")
+	client := NewClient(server.URL, "token")
+	client.Encoding = EncodingForm
+
+	_, err := client.Create(Properties{"photo": {map[string]interface{}{"value": "https://example.com/a.jpg", "alt": "A"}}})
+	if err == nil {
+		t.Error("got no error for a nested value")
+	}
+	if len(*requests) != 0 {
+		t.Errorf("got %d requests, want none", len(*requests))
+	}
+}
+
+func TestCreateWithoutLocation(t *testing.T) {
+	server, _ := newWriteServer(t, "")
+	client := NewClient(server.URL, "token")
+
+	if _, err := client.Create(Properties{"content": {"Hello"}}); err == nil {
+		t.Error("got no error for a response without a Location header")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update Update
+		want   []map[string]interface{}
+	}{
+		{
+			"replace and add",
+			Update{
+				Replace: Properties{"content": {"Hello"}},
+				Add:     Properties{"category": {"a"}},
+			},
+			[]map[string]interface{}{{
+				"action":  "update",
+				"url":     "https://example.com/1",
+				"replace": map[string]interface{}{"content": []interface{}{"Hello"}},
+				"add":     map[string]interface{}{"category": []interface{}{"a"}},
+			}},
+		},
+		{
+			"delete properties",
+			Update{DeleteProperties: []string{"name"}},
+			[]map[string]interface{}{{
+				"action": "update",
+				"url":    "https://example.com/1",
+				"delete": []interface{}{"name"},
+			}},
+		},
+		{
+			"delete values and properties",
+			Update{
+				Replace:          Properties{"content": {"Hello"}},
+				Delete:           Properties{"category": {"a"}},
+				DeleteProperties: []string{"name"},
+			},
+			[]map[string]interface{}{
+				{
+					"action":  "update",
+					"url":     "https://example.com/1",
+					"replace": map[string]interface{}{"content": []interface{}{"Hello"}},
+					"delete":  map[string]interface{}{"category": []interface{}{"a"}},
+				},
+				{
+					"action": "update",
+					"url":    "https://example.com/1",
+					"delete": []interface{}{"name"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := newWriteServer(t, "")
+			// Updates are JSON even for clients that form-encode the rest.
+			client := NewClient(server.URL, "token")
+			client.Encoding = EncodingForm
+
+			if _, err := client.Update("https://example.com/1", &test.update); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(*requests) != len(test.want) {
+				t.Fatalf("got %d requests, want %d", len(*requests), len(test.want))
+			}
+			for i, r := range *requests {
+				if got := jsonBody(t, r); !reflect.DeepEqual(got, test.want[i]) {
+					t.Errorf("request %d: got body %v, want %v", i, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteAndUndelete(t *testing.T) {
+	actions := map[string]func(*Client, string) (*Result, error){
+		"delete":   (*Client).Delete,
+		"undelete": (*Client).Undelete,
+	}
+
+	for action, do := range actions {
+		t.Run(action+" json", func(t *testing.T) {
+			server, requests := newWriteServer(t, "")
+			client := NewClient(server.URL, "token")
+			client.Destination = "https://example.com/"
+
+			if _, err := do(client, "https://example.com/1"); err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]interface{}{
+				"action":         action,
+				"url":            "https://example.com/1",
+				"mp-destination": "https://example.com/",
+			}
+			if got := jsonBody(t, (*requests)[0]); !reflect.DeepEqual(got, want) {
+				t.Errorf("got body %v, want %v", got, want)
+			}
+		})
+
+		t.Run(action+" form", func(t *testing.T) {
+			server, requests := newWriteServer(t, "")
+			client := NewClient(server.URL, "token")
+			client.Encoding = EncodingForm
+
+			if _, err := do(client, "https://example.com/1"); err != nil {
+				t.Fatal(err)
+			}
+
+			want := url.Values{"action": {action}, "url": {"https://example.com/1"}}
+			if got := formBody(t, (*requests)[0]); !reflect.DeepEqual(got, want) {
+				t.Errorf("got body %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUploadMedia(t *testing.T) {
+	var filename, contentType, data, destination string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		destination = r.FormValue("mp-destination")
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer file.Close()
+		body, _ := ioutil.ReadAll(file)
+		filename, contentType, data = header.Filename, header.Header.Get("Content-Type"), string(body)
+
+		w.Header().Set("Location", "https://media.example.com/a.jpg")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewClient("https://example.com/micropub", "token")
+	client.Destination = "https://example.com/"
+
+	result, err := client.UploadMedia(server.URL, `a "b".jpg`, "image/jpeg", strings.NewReader("JPEG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.URL != "https://media.example.com/a.jpg" {
+		t.Errorf("got URL %q", result.URL)
+	}
+	if filename != `a "b".jpg` || contentType != "image/jpeg" || data != "JPEG" {
+		t.Errorf("got file %q (%s): %q", filename, contentType, data)
+	}
+	if destination != "https://example.com/" {
+		t.Errorf("got destination %q", destination)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_request","error_description":"no such post"}`))
+	}))
+	defer server.Close()
+
+	result, err := NewClient(server.URL, "token").Delete("https://example.com/1")
+	if _, ok := err.(*Error); !ok {
+		t.Fatalf("got error %v, want an *Error", err)
+	}
+	if result.StatusCode != http.StatusBadRequest || result.Error != ErrorInvalidRequest || result.ErrorDescription != "no such post" {
+		t.Errorf("got result %+v", result)
+	}
+}
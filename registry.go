@@ -0,0 +1,284 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+// Kinds of things the registry hands out IDs for. Each kind has its own ID
+// sequence.
+const (
+	kindPost     = "post"
+	kindMedia    = "media"
+	kindCategory = "category"
+	kindTag      = "tag"
+	kindBlog     = "blog"
+)
+
+// Registry assigns durable numeric IDs to the things WordPress clients refer
+// to by ID (post URLs, media URLs, category and tag names, and Micropub
+// destinations) and persists them to a JSON file, so that IDs survive restarts
+// and never depend on the order in which the Micropub server lists things.
+type Registry struct {
+	path string
+
+	mu    sync.Mutex
+	data  registryData
+	index map[string]map[string]string // kind -> normalized key -> ID
+
+	// batches counts the open Batch calls; while there are any, writes are
+	// deferred and dirty records that one is due.
+	batches int
+	dirty   bool
+}
+
+type registryData struct {
+	Kinds   map[string]*registryKind `json:"kinds"`
+	Trashed map[string]bool          `json:"trashed"`
+	// Pending holds the IDs of categories created by clients but not yet used
+	// on any post, per catalog (see WPService.catalog).
+	Pending map[string]map[string]bool `json:"pending"`
+	// Blogs maps post IDs to the IDs of the blogs (destinations) the posts
+	// were seen on, for calls that only carry a post ID.
+	Blogs map[string]string `json:"blogs"`
+}
+
+type registryKind struct {
+	NextID int               `json:"next_id"`
+	Keys   map[string]string `json:"keys"` // ID -> key
+}
+
+// OpenRegistry loads the registry stored at path, or starts an empty one if
+// the file doesn't exist yet.
+func OpenRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+
+	if err := loadJSON(path, &r.data); err != nil {
+		return nil, err
+	}
+
+	if r.data.Kinds == nil {
+		r.data.Kinds = map[string]*registryKind{}
+	}
+	if r.data.Trashed == nil {
+		r.data.Trashed = map[string]bool{}
+	}
+	if r.data.Pending == nil {
+		r.data.Pending = map[string]map[string]bool{}
+	}
+	if r.data.Blogs == nil {
+		r.data.Blogs = map[string]string{}
+	}
+
+	r.index = map[string]map[string]string{}
+	for kind, k := range r.data.Kinds {
+		r.index[kind] = map[string]string{}
+		for id, key := range k.Keys {
+			r.index[kind][normalizeKey(kind, key)] = id
+		}
+	}
+
+	return r, nil
+}
+
+// normalizeKey returns the form of key used for lookups. Micro.blog reports
+// post URLs with http:// even when they're served over https://, so URL
+// schemes are ignored.
+func normalizeKey(kind, key string) string {
+	switch kind {
+	case kindPost, kindMedia:
+		return micropub.StripScheme(key)
+	default:
+		return key
+	}
+}
+
+// ID returns the ID for key, assigning (and persisting) a new one if key
+// hasn't been seen before.
+func (r *Registry) ID(kind, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	norm := normalizeKey(kind, key)
+	if id, ok := r.index[kind][norm]; ok {
+		return id, nil
+	}
+
+	k := r.data.Kinds[kind]
+	if k == nil {
+		k = &registryKind{NextID: 1, Keys: map[string]string{}}
+		r.data.Kinds[kind] = k
+		r.index[kind] = map[string]string{}
+	}
+
+	id := strconv.Itoa(k.NextID)
+	k.NextID++
+	k.Keys[id] = key
+	r.index[kind][norm] = id
+
+	return id, r.save()
+}
+
+// Key returns the key an ID was assigned to.
+func (r *Registry) Key(kind, id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := r.data.Kinds[kind]
+	if k == nil {
+		return "", false
+	}
+	key, ok := k.Keys[id]
+	return key, ok
+}
+
+// Rename reassigns the ID of a key to newKey, e.g. when a category is
+// renamed. If newKey had an ID of its own (e.g. a category was renamed to the
+// name of another, merging the two), that ID is retired, so that lookups of
+// newKey return id from now on, including after a reload.
+func (r *Registry) Rename(kind, id, newKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := r.data.Kinds[kind]
+	if k == nil {
+		return nil
+	}
+	oldKey, ok := k.Keys[id]
+	if !ok {
+		return nil
+	}
+
+	norm := normalizeKey(kind, newKey)
+	if other, ok := r.index[kind][norm]; ok && other != id {
+		delete(k.Keys, other)
+	}
+
+	delete(r.index[kind], normalizeKey(kind, oldKey))
+	k.Keys[id] = newKey
+	r.index[kind][norm] = id
+
+	return r.save()
+}
+
+// SetTrashed records whether the post with the given ID is in the trash.
+// Deleted posts no longer show up upstream, so this is the only record of
+// them.
+func (r *Registry) SetTrashed(id string, trashed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if trashed {
+		r.data.Trashed[id] = true
+	} else {
+		delete(r.data.Trashed, id)
+	}
+
+	return r.save()
+}
+
+// IsTrashed reports whether the post with the given ID is in the trash.
+func (r *Registry) IsTrashed(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.data.Trashed[id]
+}
+
+// SetBlog records the blog the post with the given ID belongs to.
+func (r *Registry) SetBlog(id, blogID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data.Blogs[id] == blogID {
+		return nil
+	}
+	r.data.Blogs[id] = blogID
+
+	return r.save()
+}
+
+// Blog returns the ID of the blog the post with the given ID belongs to.
+func (r *Registry) Blog(id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blogID, ok := r.data.Blogs[id]
+	return blogID, ok
+}
+
+// SetPending records whether the category with the given ID is pending in a
+// catalog.
+func (r *Registry) SetPending(catalog, id string, pending bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := r.data.Pending[catalog]
+	if pending == ids[id] {
+		return nil
+	}
+
+	if pending {
+		if ids == nil {
+			ids = map[string]bool{}
+			r.data.Pending[catalog] = ids
+		}
+		ids[id] = true
+	} else {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(r.data.Pending, catalog)
+		}
+	}
+
+	return r.save()
+}
+
+// Pending returns the IDs of the categories pending in a catalog, in no
+// particular order.
+func (r *Registry) Pending(catalog string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []string{}
+	for id := range r.data.Pending[catalog] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Batch calls fn with the registry's writes deferred until it returns, so
+// that a call assigning many IDs (e.g. the first listing of a large blog)
+// writes the file once rather than once per ID. Batches may nest and overlap;
+// the file is written when the last one ends.
+func (r *Registry) Batch(fn func() error) error {
+	r.mu.Lock()
+	r.batches++
+	r.mu.Unlock()
+
+	err := fn()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches--
+	if r.batches == 0 && r.dirty {
+		if saveErr := r.save(); err == nil {
+			err = saveErr
+		}
+	}
+
+	return err
+}
+
+// save writes the registry to disk, or marks it dirty during a batch. The
+// caller must hold r.mu.
+func (r *Registry) save() error {
+	if r.batches > 0 {
+		r.dirty = true
+		return nil
+	}
+	r.dirty = false
+	return saveJSON(r.path, &r.data)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func openTestRegistry(t *testing.T, path string) *Registry {
+	r, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func mustID(t *testing.T, r *Registry, kind, key string) string {
+	id, err := r.ID(kind, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestRegistryIDsSurviveReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.json")
+	r := openTestRegistry(t, path)
+
+	ids := map[string]string{}
+	for _, k := range []struct{ kind, key string }{
+		{kindPost, "https://example.com/1"},
+		{kindPost, "https://example.com/2"},
+		{kindCategory, "Travel"},
+		{kindMedia, "https://example.com/a.jpg"},
+		{kindCategory, "Food"},
+	} {
+		ids[k.kind+" "+k.key] = mustID(t, r, k.kind, k.key)
+	}
+
+	want := map[string]string{
+		"post https://example.com/1":      "1",
+		"post https://example.com/2":      "2",
+		"category Travel":                 "1",
+		"media https://example.com/a.jpg": "1",
+		"category Food":                   "2",
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+
+	r = openTestRegistry(t, path)
+
+	tests := []struct {
+		kind, key, want string
+	}{
+		{kindPost, "https://example.com/2", "2"},
+		{kindPost, "http://example.com/1", "1"}, // schemes are ignored
+		{kindCategory, "Food", "2"},
+		{kindCategory, "food", "3"}, // category names aren't normalized
+		{kindPost, "https://example.com/3", "3"},
+		{kindMedia, "https://example.com/a.jpg", "1"},
+	}
+	for _, test := range tests {
+		if got := mustID(t, r, test.kind, test.key); got != test.want {
+			t.Errorf("ID(%s, %s) = %s, want %s", test.kind, test.key, got, test.want)
+		}
+	}
+
+	if key, ok := r.Key(kindPost, "1"); !ok || key != "https://example.com/1" {
+		t.Errorf("Key(post, 1) = %q, %v", key, ok)
+	}
+	if _, ok := r.Key(kindTag, "1"); ok {
+		t.Error("Key(tag, 1) found a key for a kind without IDs")
+	}
+}
+
+func TestRegistryRename(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.json")
+	r := openTestRegistry(t, path)
+
+	travel := mustID(t, r, kindCategory, "Travel")
+	food := mustID(t, r, kindCategory, "Food")
+
+	if err := r.Rename(kindCategory, travel, "Trips"); err != nil {
+		t.Fatal(err)
+	}
+	// Renaming onto an existing name merges the two, keeping the renamed
+	// category's ID.
+	if err := r.Rename(kindCategory, travel, "Food"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*Registry{r, openTestRegistry(t, path)} {
+		if got := mustID(t, r, kindCategory, "Food"); got != travel {
+			t.Errorf("ID(Food) = %s, want %s", got, travel)
+		}
+		if key, ok := r.Key(kindCategory, travel); !ok || key != "Food" {
+			t.Errorf("Key(%s) = %q, %v, want Food", travel, key, ok)
+		}
+		if key, ok := r.Key(kindCategory, food); ok {
+			t.Errorf("Key(%s) = %q, want the merged ID retired", food, key)
+		}
+	}
+
+	r = openTestRegistry(t, path)
+	if got := mustID(t, r, kindCategory, "Trips"); got == travel || got == food {
+		t.Errorf("ID(Trips) = %s, want a new ID", got)
+	}
+	if err := r.Rename(kindCategory, "99", "Nothing"); err != nil {
+		t.Errorf("renaming an unknown ID: %v", err)
+	}
+}
+
+func TestRegistryBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.json")
+	r := openTestRegistry(t, path)
+
+	written := func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	err := r.Batch(func() error {
+		for _, key := range []string{"https://example.com/1", "https://example.com/2"} {
+			mustID(t, r, kindPost, key)
+		}
+		if err := r.Batch(func() error {
+			mustID(t, r, kindPost, "https://example.com/3")
+			return r.SetTrashed("3", true)
+		}); err != nil {
+			return err
+		}
+		if written() {
+			t.Error("registry written before the outermost batch ended")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !written() {
+		t.Fatal("registry not written when the batch ended")
+	}
+
+	r = openTestRegistry(t, path)
+	if got := mustID(t, r, kindPost, "https://example.com/3"); got != "3" {
+		t.Errorf("ID(3) = %s after reload, want 3", got)
+	}
+	if !r.IsTrashed("3") {
+		t.Error("trashed post not saved by the batch")
+	}
+
+	// Batches that change nothing don't write anything.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Batch(func() error {
+		mustID(t, r, kindPost, "https://example.com/1")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if written() {
+		t.Error("registry written by a batch that changed nothing")
+	}
+}
+
+func TestRegistryTrashedAndPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.json")
+	r := openTestRegistry(t, path)
+
+	for _, step := range []struct {
+		id      string
+		trashed bool
+	}{{"1", true}, {"2", true}, {"1", false}} {
+		if err := r.SetTrashed(step.id, step.trashed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, step := range []struct {
+		catalog, id string
+		pending     bool
+	}{
+		{"a", "1", true},
+		{"a", "2", true},
+		{"b", "3", true},
+		{"a", "1", false},
+		{"b", "3", false},
+		{"b", "4", false},
+	} {
+		if err := r.SetPending(step.catalog, step.id, step.pending); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, r := range []*Registry{r, openTestRegistry(t, path)} {
+		if r.IsTrashed("1") || !r.IsTrashed("2") || r.IsTrashed("3") {
+			t.Errorf("trashed: 1=%v 2=%v 3=%v, want only 2", r.IsTrashed("1"), r.IsTrashed("2"), r.IsTrashed("3"))
+		}
+
+		pending := r.Pending("a")
+		sort.Strings(pending)
+		if !reflect.DeepEqual(pending, []string{"2"}) {
+			t.Errorf("Pending(a) = %v, want [2]", pending)
+		}
+		if pending := r.Pending("b"); len(pending) != 0 {
+			t.Errorf("Pending(b) = %v, want none", pending)
+		}
+		if _, ok := r.data.Pending["b"]; ok {
+			t.Error("empty catalog b kept")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ScheduledPost is a post held as a draft upstream until the scheduler
+// publishes it. The scheduler runs outside of any XML-RPC call, so the
+// client's token is kept along with it.
+type ScheduledPost struct {
+	URL         string    `json:"url"`
+	Endpoint    string    `json:"endpoint"`
+	Destination string    `json:"destination,omitempty"`
+	Token       string    `json:"token"`
+	Date        time.Time `json:"date"`
+}
+
+// Schedule holds the posts waiting to be published, keyed by WordPress post
+// ID, and persists them to a JSON file so that they survive restarts. The
+// file contains tokens and is only readable by its owner.
+type Schedule struct {
+	path string
+
+	mu    sync.Mutex
+	posts map[string]*ScheduledPost
+}
+
+// OpenSchedule loads the schedule stored at path, or starts an empty one if
+// the file doesn't exist yet.
+func OpenSchedule(path string) (*Schedule, error) {
+	s := &Schedule{path: path}
+
+	if err := loadJSON(path, &s.posts); err != nil {
+		return nil, err
+	}
+
+	if s.posts == nil {
+		s.posts = map[string]*ScheduledPost{}
+	}
+
+	return s, nil
+}
+
+// Get returns the scheduled post with the given ID.
+func (s *Schedule) Get(id string) (ScheduledPost, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok {
+		return ScheduledPost{}, false
+	}
+	return *post, true
+}
+
+// Set schedules the post with the given ID, replacing any earlier entry.
+func (s *Schedule) Set(id string, post ScheduledPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.posts[id] = &post
+	return s.save()
+}
+
+// Remove unschedules the post with the given ID. Removing a post that isn't
+// scheduled is a no-op.
+func (s *Schedule) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return nil
+	}
+	delete(s.posts, id)
+	return s.save()
+}
+
+// Due returns the posts scheduled at or before now.
+func (s *Schedule) Due(now time.Time) map[string]ScheduledPost {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := map[string]ScheduledPost{}
+	for id, post := range s.posts {
+		if !post.Date.After(now) {
+			due[id] = *post
+		}
+	}
+	return due
+}
+
+// save writes the schedule to disk. The caller must hold s.mu.
+func (s *Schedule) save() error {
+	return saveJSON(s.path, s.posts)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Upload is a file uploaded to a media endpoint through the bridge. Owner
+// identifies the account that uploaded it (see uploadOwner).
+type Upload struct {
+	Owner       string    `json:"owner"`
+	URL         string    `json:"url"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Endpoint    string    `json:"endpoint"`
+	Destination string    `json:"destination,omitempty"`
+	Date        time.Time `json:"date"`
+}
+
+// Uploads records the files uploaded through the bridge, keyed by WordPress
+// attachment ID, so that the media library can be listed for media endpoints
+// that can't list their files. The record is persisted to a JSON file.
+type Uploads struct {
+	path string
+
+	mu      sync.Mutex
+	uploads map[string]*Upload
+}
+
+// OpenUploads loads the record stored at path, or starts an empty one if the
+// file doesn't exist yet.
+func OpenUploads(path string) (*Uploads, error) {
+	u := &Uploads{path: path}
+
+	if err := loadJSON(path, &u.uploads); err != nil {
+		return nil, err
+	}
+
+	if u.uploads == nil {
+		u.uploads = map[string]*Upload{}
+	}
+
+	return u, nil
+}
+
+// Add records an upload under the given ID.
+func (u *Uploads) Add(id string, upload Upload) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.uploads[id] = &upload
+	return u.save()
+}
+
+// List returns the uploads owner made to the given media endpoint and
+// destination, keyed by ID.
+func (u *Uploads) List(owner, endpoint, destination string) map[string]Upload {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	uploads := map[string]Upload{}
+	for id, upload := range u.uploads {
+		if upload.Owner == owner && upload.Endpoint == endpoint && upload.Destination == destination {
+			uploads[id] = *upload
+		}
+	}
+	return uploads
+}
+
+// save writes the record to disk. The caller must hold u.mu.
+func (u *Uploads) save() error {
+	return saveJSON(u.path, u.uploads)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestUploadsList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploads.json")
+	uploads, err := OpenUploads(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, upload := range map[string]Upload{
+		"1": {Owner: "me:https://a.example/", Endpoint: "https://media.example/", URL: "https://media.example/1.jpg"},
+		"2": {Owner: "me:https://b.example/", Endpoint: "https://media.example/", URL: "https://media.example/2.jpg"},
+		"3": {Owner: "me:https://a.example/", Endpoint: "https://other.example/", URL: "https://other.example/3.jpg"},
+		"4": {Owner: "me:https://a.example/", Endpoint: "https://media.example/", Destination: "https://a.example/blog", URL: "https://media.example/4.jpg"},
+		"5": {Owner: "token:abc", Endpoint: "https://media.example/", URL: "https://media.example/5.jpg"},
+	} {
+		if err := uploads.Add(id, upload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopen the record to check that the owner survives a reload.
+	if uploads, err = OpenUploads(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		owner       string
+		endpoint    string
+		destination string
+		want        []string
+	}{
+		{"owner", "me:https://a.example/", "https://media.example/", "", []string{"1"}},
+		{"other owner", "me:https://b.example/", "https://media.example/", "", []string{"2"}},
+		{"token owner", "token:abc", "https://media.example/", "", []string{"5"}},
+		{"other endpoint", "me:https://a.example/", "https://other.example/", "", []string{"3"}},
+		{"destination", "me:https://a.example/", "https://media.example/", "https://a.example/blog", []string{"4"}},
+		{"unknown owner", "me:https://c.example/", "https://media.example/", "", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []string{}
+			for id := range uploads.List(test.owner, test.endpoint, test.destination) {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUploadOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		account Account
+		want    string
+	}{
+		{
+			"verified profile",
+			Account{Me: "https://a.example/", TokenEndpoint: "https://tokens.example/", Token: "t1"},
+			"me:https://a.example/",
+		},
+		{"unverified profile", Account{Me: "https://a.example/", Token: "t1"}, "token:" + hashToken("t1")},
+		{"token only", Account{Token: "t2"}, "token:" + hashToken("t2")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := uploadOwner(&test.account); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
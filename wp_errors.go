@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/codykrieger/microbridge/micropub"
+	"github.com/codykrieger/microbridge/xmlrpc"
+)
+
+// faultFromError translates errors returned by the Micropub server into
+// faults that WordPress clients can show to the user. Other errors are
+// returned unchanged.
+func faultFromError(err error) error {
+	e, ok := err.(*micropub.Error)
+	if !ok {
+		return err
+	}
+
+	fault := &xmlrpc.FaultError{StatusCode: e.StatusCode, Text: e.Status}
+
+	switch e.Code {
+	case micropub.ErrorInsufficientScope:
+		fault.StatusCode = http.StatusUnauthorized
+		if e.Scope != "" {
+			fault.Text = "token lacks " + e.Scope + " scope"
+		} else {
+			fault.Text = "token lacks the scope required for this action"
+		}
+	case micropub.ErrorUnauthorized:
+		fault.StatusCode = http.StatusForbidden
+		fault.Text = "invalid or expired token"
+	case micropub.ErrorForbidden:
+		fault.StatusCode = http.StatusForbidden
+		fault.Text = "forbidden"
+	case micropub.ErrorInvalidRequest:
+		fault.StatusCode = http.StatusBadRequest
+		fault.Text = "invalid request"
+	}
+
+	if e.Description != "" {
+		fault.Text += ": " + e.Description
+	}
+
+	return fault
+}
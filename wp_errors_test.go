@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codykrieger/microbridge/micropub"
+	"github.com/codykrieger/microbridge/xmlrpc"
+)
+
+func TestFaultFromError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *micropub.Error
+		want xmlrpc.FaultError
+	}{
+		{
+			"insufficient scope",
+			&micropub.Error{StatusCode: 401, Status: "401 Unauthorized", Code: micropub.ErrorInsufficientScope, Scope: "create"},
+			xmlrpc.FaultError{StatusCode: 401, Text: "token lacks create scope"},
+		},
+		{
+			"insufficient scope, unnamed",
+			&micropub.Error{StatusCode: 403, Status: "403 Forbidden", Code: micropub.ErrorInsufficientScope},
+			xmlrpc.FaultError{StatusCode: 401, Text: "token lacks the scope required for this action"},
+		},
+		{
+			"unauthorized",
+			&micropub.Error{StatusCode: 401, Status: "401 Unauthorized", Code: micropub.ErrorUnauthorized},
+			xmlrpc.FaultError{StatusCode: 403, Text: "invalid or expired token"},
+		},
+		{
+			"forbidden",
+			&micropub.Error{StatusCode: 403, Status: "403 Forbidden", Code: micropub.ErrorForbidden, Description: "not yours"},
+			xmlrpc.FaultError{StatusCode: 403, Text: "forbidden: not yours"},
+		},
+		{
+			"invalid request",
+			&micropub.Error{StatusCode: 400, Status: "400 Bad Request", Code: micropub.ErrorInvalidRequest, Description: "missing url"},
+			xmlrpc.FaultError{StatusCode: 400, Text: "invalid request: missing url"},
+		},
+		{
+			"unknown code",
+			&micropub.Error{StatusCode: 500, Status: "500 Internal Server Error"},
+			xmlrpc.FaultError{StatusCode: 500, Text: "500 Internal Server Error"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fault, ok := faultFromError(test.err).(*xmlrpc.FaultError)
+			if !ok {
+				t.Fatalf("got %v, want a fault", fault)
+			}
+			if *fault != test.want {
+				t.Errorf("got %+v, want %+v", *fault, test.want)
+			}
+		})
+	}
+
+	t.Run("other error", func(t *testing.T) {
+		err := errors.New("connection refused")
+		if got := faultFromError(err); got != err {
+			t.Errorf("got %v, want the error unchanged", got)
+		}
+	})
+}
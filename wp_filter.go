@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+// defaultPostsNumber is how many posts wp.getPosts returns when the client
+// doesn't say, as in WordPress.
+const defaultPostsNumber = 10
+
+// sourceQuery translates a wp.getPosts filter into the paging and status
+// parameters of a Micropub source query.
+//
+// Servers may ignore any of these, so filterPosts is always applied to the
+// result as well. To keep that safe, the offset is never sent upstream;
+// instead, the limit covers every post up to the end of the requested page.
+// Micropub servers list newest posts first, so that only works when the client
+// wants posts in that order. It also only works when the server won't have to
+// filter anything: a server that honors the limit but ignores post-status
+// would return too few matching posts, and so would a limit covering both
+// posts and pages when the blog has pages.
+func sourceQuery(filter *PostFilter, hasPages bool) *micropub.SourceQuery {
+	query := &micropub.SourceQuery{}
+
+	switch {
+	case filter.PostStatus == "future":
+		// Scheduled posts are drafts upstream when the bridge schedules them
+		// and published posts otherwise, so the query can't be narrowed down.
+	case filter.PostStatus != "":
+		query.PostStatus = micropubStatus(filter.PostStatus)
+	case isDefaultOrder(filter) && !hasPages:
+		query.Limit = filter.Offset + postsNumber(filter)
+	}
+
+	return query
+}
+
+// filterPosts applies the type and status filters, ordering and paging of a wp.getPosts
+// filter to posts.
+func filterPosts(posts []Post, filter *PostFilter) []Post {
+	postType := filter.PostType
+	if postType == "" {
+		postType = "post"
+	}
+
+	filtered := []Post{}
+	for _, post := range posts {
+		if post.Type != postType {
+			continue
+		}
+		if filter.PostStatus == "" || post.Status == filter.PostStatus {
+			filtered = append(filtered, post)
+		}
+	}
+
+	less := postLess(filter.OrderBy)
+	desc := !strings.EqualFold(filter.Order, "asc")
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if desc {
+			return less(&filtered[j], &filtered[i])
+		}
+		return less(&filtered[i], &filtered[j])
+	})
+
+	if filter.Offset >= len(filtered) {
+		return []Post{}
+	}
+	filtered = filtered[filter.Offset:]
+
+	if number := postsNumber(filter); number < len(filtered) {
+		filtered = filtered[:number]
+	}
+
+	return filtered
+}
+
+func postsNumber(filter *PostFilter) int {
+	if filter.Number <= 0 {
+		return defaultPostsNumber
+	}
+	return filter.Number
+}
+
+// isDefaultOrder reports whether the filter asks for posts newest first.
+func isDefaultOrder(filter *PostFilter) bool {
+	switch filter.OrderBy {
+	case "", "date", "post_date":
+	default:
+		return false
+	}
+	return !strings.EqualFold(filter.Order, "asc")
+}
+
+// postLess returns the ascending ordering of posts for a wp.getPosts orderby
+// value. Unknown values order by date.
+func postLess(orderBy string) func(a, b *Post) bool {
+	switch orderBy {
+	case "title", "post_title":
+		return func(a, b *Post) bool { return a.Title < b.Title }
+	case "modified", "post_modified":
+		return func(a, b *Post) bool { return a.DateModified.Before(b.DateModified) }
+	case "name", "post_name":
+		return func(a, b *Post) bool { return a.Name < b.Name }
+	case "ID", "post_id":
+		return func(a, b *Post) bool { return postIDLess(a.PostID, b.PostID) }
+	default:
+		return func(a, b *Post) bool { return a.Date.Before(b.Date) }
+	}
+}
+
+// postIDLess compares post IDs numerically.
+func postIDLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
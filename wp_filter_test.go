@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testPost(id int, postType, status, title string, day int) Post {
+	return Post{
+		PostID: strconv.Itoa(id),
+		Type:   postType,
+		Status: status,
+		Title:  title,
+		Date:   time.Date(2020, 1, day, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestFilterPosts(t *testing.T) {
+	posts := []Post{
+		testPost(1, "post", "publish", "c", 1),
+		testPost(2, "post", "draft", "a", 2),
+		testPost(3, "page", "publish", "b", 3),
+		testPost(10, "post", "publish", "b", 4),
+		testPost(5, "post", "future", "d", 5),
+	}
+
+	many := []Post{}
+	for i := 1; i <= 15; i++ {
+		many = append(many, testPost(i, "post", "publish", "", i))
+	}
+
+	tests := []struct {
+		name   string
+		posts  []Post
+		filter PostFilter
+		want   []string
+	}{
+		{"defaults", posts, PostFilter{}, []string{"5", "10", "2", "1"}},
+		{"pages", posts, PostFilter{PostType: "page"}, []string{"3"}},
+		{"status", posts, PostFilter{PostStatus: "publish"}, []string{"10", "1"}},
+		{"ascending", posts, PostFilter{Order: "ASC"}, []string{"1", "2", "10", "5"}},
+		{"by title", posts, PostFilter{OrderBy: "title", Order: "asc"}, []string{"2", "10", "1", "5"}},
+		{"by ID", posts, PostFilter{OrderBy: "ID", Order: "asc"}, []string{"1", "2", "5", "10"}},
+		{"unknown orderby", posts, PostFilter{OrderBy: "rand"}, []string{"5", "10", "2", "1"}},
+		{"number", posts, PostFilter{Number: 2}, []string{"5", "10"}},
+		{"offset", posts, PostFilter{Offset: 1, Number: 2}, []string{"10", "2"}},
+		{"offset past end", posts, PostFilter{Offset: 4}, []string{}},
+		{"default number", many, PostFilter{Order: "asc"}, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}},
+		{"negative number", many, PostFilter{Number: -1, Offset: 10}, []string{"5", "4", "3", "2", "1"}},
+		{"no posts", nil, PostFilter{}, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []string{}
+			for _, post := range filterPosts(test.posts, &test.filter) {
+				got = append(got, post.PostID)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codykrieger/microbridge/micropub"
+	"github.com/codykrieger/microbridge/xmlrpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// mediaEndpoint returns the media endpoint of the account behind username and
+// password. Sites may advertise their media endpoint with a link rather than
+// in the Micropub config.
+func (s *WPService) mediaEndpoint(client *micropub.Client, username, password string) (string, error) {
+	config, err := s.micropubConfig(client)
+	if err != nil {
+		return "", err
+	}
+	if config.MediaEndpoint != "" {
+		return config.MediaEndpoint, nil
+	}
+
+	account, err := s.account(username, password)
+	if err != nil {
+		return "", err
+	}
+	if account.MediaEndpoint == "" {
+		return "", ErrNoMediaEndpoint
+	}
+
+	return account.MediaEndpoint, nil
+}
+
+// uploadOwner returns who the uploads made with an account belong to: the
+// profile URL its token was verified to be issued for, or else the token
+// itself, hashed. Several tokens can share a Micropub endpoint and
+// destination, so uploads can't be told apart by those alone.
+func uploadOwner(account *Account) string {
+	if account.TokenEndpoint != "" && account.Me != "" {
+		return "me:" + account.Me
+	}
+	return "token:" + hashToken(account.Token)
+}
+
+// attachment returns the WordPress attachment for an uploaded file. Images
+// are their own thumbnails.
+func attachment(id, url, contentType string, date time.Time) PostThumbnail {
+	name := path.Base(url)
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+
+	thumbnail := ""
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnail = url
+	}
+
+	return PostThumbnail{
+		AttachmentID:   id,
+		DateCreatedGMT: date.UTC(),
+		Link:           url,
+		Title:          name,
+		Type:           contentType,
+		Thumbnail:      thumbnail,
+		Metadata:       MediaMetadata{File: name},
+	}
+}
+
+// mediaLibrary returns the files uploaded to the media endpoint, newest
+// first: those the endpoint lists, if it can, plus those owner uploaded through
+// the bridge that it doesn't list. Only auth errors fail the listing; on any
+// other error, such as an endpoint that can't list files or responds with
+// something other than JSON, just the local uploads are returned.
+func (s *WPService) mediaLibrary(client *micropub.Client, mediaEndpoint, owner string) ([]PostThumbnail, error) {
+	items, err := client.GetMedia(mediaEndpoint, nil)
+	if err != nil {
+		if micropub.IsAuthError(err) {
+			return nil, err
+		}
+		log.WithError(err).Warn("media endpoint can't list files; using local uploads")
+		items = nil
+	}
+
+	uploads := s.uploads.List(owner, mediaEndpoint, client.Destination)
+
+	library := []PostThumbnail{}
+	seen := map[string]bool{}
+
+	err = s.ids.Batch(func() error {
+		for _, item := range items {
+			id, err := s.ids.ID(kindMedia, item.URL)
+			if err != nil {
+				return err
+			}
+
+			upload := uploads[id]
+			date := upload.Date
+			if item.Published != "" {
+				if date, err = parsePublished(item.Published, s.config.Location); err != nil {
+					return err
+				}
+			}
+
+			a := attachment(id, item.URL, upload.Type, date)
+			a.Caption = item.Alt
+			library = append(library, a)
+			seen[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for id, upload := range uploads {
+		if !seen[id] {
+			library = append(library, attachment(id, upload.URL, upload.Type, upload.Date))
+		}
+	}
+
+	sort.SliceStable(library, func(i, j int) bool {
+		a, b := &library[i], &library[j]
+		if !a.DateCreatedGMT.Equal(b.DateCreatedGMT) {
+			return a.DateCreatedGMT.After(b.DateCreatedGMT)
+		}
+		return postIDLess(b.AttachmentID, a.AttachmentID)
+	})
+
+	return library, nil
+}
+
+// filterMedia applies the mime_type and paging of a wp.getMediaLibrary filter
+// to library. Attachments aren't tied to posts, so asking for those of a post
+// returns none.
+func filterMedia(library []PostThumbnail, filter *MediaFilter) []PostThumbnail {
+	filtered := []PostThumbnail{}
+	if filter.ParentID > 0 {
+		return filtered
+	}
+
+	for _, a := range library {
+		if filter.MIMEType == "" || strings.HasPrefix(a.Type, filter.MIMEType) {
+			filtered = append(filtered, a)
+		}
+	}
+
+	if filter.Offset >= len(filtered) {
+		return []PostThumbnail{}
+	}
+	filtered = filtered[filter.Offset:]
+
+	if filter.Number > 0 && filter.Number < len(filtered) {
+		filtered = filtered[:filter.Number]
+	}
+
+	return filtered
+}
+
+type GetMediaLibraryArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Filter   MediaFilter
+}
+
+type GetMediaLibraryReply struct {
+	Media []PostThumbnail
+}
+
+func (s *WPService) GetMediaLibrary(req *http.Request, args *GetMediaLibraryArgs, reply *GetMediaLibraryReply) error {
+	log.WithFields(log.Fields{
+		"bid":    args.BlogID,
+		"u":      args.Username,
+		"filter": args.Filter,
+	}).Info("---> wp.GetMediaLibrary")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	mediaEndpoint, err := s.mediaEndpoint(client, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	account, err := s.account(args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	library, err := s.mediaLibrary(client, mediaEndpoint, uploadOwner(&account))
+	if err != nil {
+		return err
+	}
+
+	reply.Media = filterMedia(library, &args.Filter)
+
+	return nil
+}
+
+type GetMediaItemArgs struct {
+	BlogID       string
+	Username     string
+	Password     string
+	AttachmentID string
+}
+
+type GetMediaItemReply struct {
+	Item PostThumbnail
+}
+
+func (s *WPService) GetMediaItem(req *http.Request, args *GetMediaItemArgs, reply *GetMediaItemReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"aid": args.AttachmentID,
+	}).Info("---> wp.GetMediaItem")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	mediaEndpoint, err := s.mediaEndpoint(client, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	account, err := s.account(args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	library, err := s.mediaLibrary(client, mediaEndpoint, uploadOwner(&account))
+	if err != nil {
+		return err
+	}
+
+	for _, a := range library {
+		if a.AttachmentID == args.AttachmentID {
+			reply.Item = a
+			return nil
+		}
+	}
+
+	return xmlrpc.ErrNotFound
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+func TestMediaLibrary(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   []string
+		err    bool
+	}{
+		{
+			"listed",
+			http.StatusOK,
+			`{"items":[{"url":"https://media.example/listed.jpg","published":"2020-01-03T00:00:00Z"}]}`,
+			[]string{"https://media.example/listed.jpg", "https://media.example/mine.jpg"},
+			false,
+		},
+		{"can't list", http.StatusBadRequest, `{"error":"invalid_request"}`, []string{"https://media.example/mine.jpg"}, false},
+		{"not found", http.StatusNotFound, `Not Found`, []string{"https://media.example/mine.jpg"}, false},
+		{"server error", http.StatusInternalServerError, ``, []string{"https://media.example/mine.jpg"}, false},
+		{"not JSON", http.StatusOK, `<html></html>`, []string{"https://media.example/mine.jpg"}, false},
+		{"unauthorized", http.StatusUnauthorized, `{"error":"unauthorized"}`, nil, true},
+		{"forbidden", http.StatusForbidden, `{"error":"forbidden"}`, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			s := testServiceWithStores(t)
+			var err error
+			if s.uploads, err = OpenUploads(filepath.Join(t.TempDir(), "uploads.json")); err != nil {
+				t.Fatal(err)
+			}
+			date := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+			for _, upload := range []Upload{
+				{Owner: "me:https://a.example/", Endpoint: server.URL, URL: "https://media.example/mine.jpg", Date: date},
+				{Owner: "me:https://b.example/", Endpoint: server.URL, URL: "https://media.example/theirs.jpg", Date: date},
+			} {
+				id, err := s.ids.ID(kindMedia, upload.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := s.uploads.Add(id, upload); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := micropub.NewClient("https://example.com/micropub", "token")
+			library, err := s.mediaLibrary(client, server.URL, "me:https://a.example/")
+			if test.err {
+				if !micropub.IsAuthError(err) {
+					t.Errorf("got error %v, want an auth error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, a := range library {
+				got = append(got, a.Link)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
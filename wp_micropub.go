@@ -0,0 +1,546 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/codykrieger/microbridge/micropub"
+	"github.com/codykrieger/microbridge/xmlrpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// micropubStatus maps a WordPress post status onto a Micropub post-status.
+func micropubStatus(wpStatus string) string {
+	switch wpStatus {
+	case "publish", "future":
+		return "published"
+	case "draft", "pending", "private":
+		return "draft"
+	default:
+		log.Warnf("unknown wordpress post status '%s'", wpStatus)
+		return "draft"
+	}
+}
+
+// wpStatus maps a Micropub post-status onto a WordPress post status.
+func wpStatus(status string) string {
+	switch status {
+	case "published":
+		return "publish"
+	case "draft":
+		return "draft"
+	default:
+		log.Warnf("unknown micropub post status '%s'", status)
+		return "publish"
+	}
+}
+
+// postFromItem translates a Micropub item into a WordPress post of the given
+// type ("post" or "page"). Dates are reported in the blog's timezone, with
+// UTC copies in the _gmt fields.
+func (s *WPService) postFromItem(id string, item *micropub.Item, postType string, split *termSplitter) (Post, error) {
+	props := &item.Properties
+	loc := s.config.Location
+
+	terms, err := s.itemTerms(item, split)
+	if err != nil {
+		return Post{}, err
+	}
+
+	var date time.Time
+	if len(props.Published) > 0 {
+		if date, err = parsePublished(props.Published[0], loc); err != nil {
+			return Post{}, err
+		}
+	}
+
+	post := Post{
+		PostID:          id,
+		Title:           first(props.Name),
+		Date:            date.In(loc),
+		DateGMT:         date.UTC(),
+		DateModified:    date.In(loc),
+		DateModifiedGMT: date.UTC(),
+		Status:          wpStatus(first(props.PostStatus)),
+		Type:            postType,
+		Format:          "standard",
+		Name:            itemSlug(item),
+		Author:          "1",
+		Content:         first(props.Content),
+		Excerpt:         first(props.Strings("summary")),
+		Parent:          "0",
+		MIMEType:        "text/plain",
+		Link:            first(props.URL),
+		CommentStatus:   "closed",
+		PingStatus:      "closed",
+		Sticky:          false,
+		Terms:           terms,
+		CustomFields:    []CustomField{},
+		Enclosure:       itemEnclosure(item),
+	}
+	post.Status = s.postStatus(&post)
+
+	return post, nil
+}
+
+// defaultPostFields are the fields returned by wp.getPost when the client
+// doesn't ask for specific ones.
+var defaultPostFields = []string{"post", "terms", "custom_fields", "enclosure"}
+
+// postFields returns the members of post that were asked for. Fields may name
+// individual members (e.g. "post_title") or the groups WordPress defines:
+// "post" for every basic member, and "terms", "custom_fields" and "enclosure".
+// The post ID is always included.
+func postFields(post *Post, fields []string) xmlrpc.Struct {
+	if len(fields) == 0 {
+		fields = defaultPostFields
+	}
+
+	value := reflect.ValueOf(post).Elem()
+	members := xmlrpc.Struct{}
+
+	for i := 0; i < value.NumField(); i++ {
+		name := strings.Split(value.Type().Field(i).Tag.Get("xml"), ",")[0]
+
+		group := "post"
+		switch name {
+		case "terms", "custom_fields", "enclosure":
+			group = name
+		}
+
+		if name == "post_id" || contains(fields, name) || contains(fields, group) {
+			members = append(members, xmlrpc.Member{Name: name, Value: value.Field(i).Interface()})
+		}
+	}
+
+	return members
+}
+
+// postID returns the WordPress post ID for an item, or "" if the item has no
+// URL to identify it by.
+func (s *WPService) postID(client *micropub.Client, item *micropub.Item) (string, error) {
+	if len(item.Properties.URL) == 0 {
+		return "", nil
+	}
+	return s.urlPostID(client, item.Properties.URL[0])
+}
+
+// urlPostID returns the WordPress post ID for the item at url. The blog the
+// client is directed at is recorded as the post's blog (see checkPostAuth).
+func (s *WPService) urlPostID(client *micropub.Client, url string) (string, error) {
+	id, err := s.ids.ID(kindPost, url)
+	if err != nil || client.Destination == "" {
+		return id, err
+	}
+
+	blogID, err := s.ids.ID(kindBlog, client.Destination)
+	if err != nil {
+		return "", err
+	}
+
+	return id, s.ids.SetBlog(id, blogID)
+}
+
+// listPosts returns every item matching query as a WordPress post.
+func (s *WPService) listPosts(client *micropub.Client, query *micropub.SourceQuery) ([]Post, error) {
+	items, err := client.GetPosts(query)
+	if err != nil {
+		return nil, err
+	}
+
+	split := s.termSplitter()
+
+	posts := []Post{}
+
+	err = s.ids.Batch(func() error {
+		for _, item := range items {
+			id, err := s.postID(client, item)
+			if err != nil {
+				return err
+			}
+			if id == "" {
+				log.Warn("skipping micropub item without a url")
+				continue
+			}
+
+			post, err := s.postFromItem(id, item, s.postType(item), split)
+			if err != nil {
+				return err
+			}
+
+			posts = append(posts, post)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// findPost returns the item with the given WordPress post ID, or nil if there
+// is none.
+func (s *WPService) findPost(client *micropub.Client, id string) (*micropub.Item, error) {
+	url, ok := s.ids.Key(kindPost, id)
+	if !ok {
+		return nil, nil
+	}
+	return client.GetPost(url)
+}
+
+// trashPost deletes the post with the given ID upstream. Posts in the trash
+// can be restored with restorePost.
+func (s *WPService) trashPost(client *micropub.Client, id string) error {
+	item, err := s.findPost(client, id)
+	if err != nil {
+		return err
+	}
+	if item == nil || len(item.Properties.URL) == 0 {
+		return xmlrpc.ErrNotFound
+	}
+
+	if _, err := client.Delete(item.Properties.URL[0]); err != nil {
+		return err
+	}
+
+	log.WithField("url", item.Properties.URL[0]).Info("deleted post")
+
+	if err := s.schedule.Remove(id); err != nil {
+		return err
+	}
+
+	return s.ids.SetTrashed(id, true)
+}
+
+// restorePost undeletes a post previously moved to the trash by trashPost and
+// returns it, or returns nil if the post isn't in the trash.
+func (s *WPService) restorePost(client *micropub.Client, id string) (*micropub.Item, error) {
+	url, ok := s.ids.Key(kindPost, id)
+	if !ok || !s.ids.IsTrashed(id) {
+		return nil, nil
+	}
+
+	if _, err := client.Undelete(url); err != nil {
+		return nil, err
+	}
+
+	log.WithField("url", url).Info("restored post")
+	if err := s.ids.SetTrashed(id, false); err != nil {
+		return nil, err
+	}
+
+	return client.GetPost(url)
+}
+
+// termNames resolves WordPress category or tag IDs, as handed out by
+// wp.getCategories, wp.getTags and wp.getTerms, to names.
+func (s *WPService) termNames(kind string, ids []string) []string {
+	names := []string{}
+	for _, id := range ids {
+		name, ok := s.ids.Key(kind, id)
+		if !ok {
+			log.Warnf("unknown %s id '%s'", kind, id)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// propertiesFromContent translates the content of a wp.newPost call into the
+// properties of a Micropub create request.
+func (s *WPService) propertiesFromContent(content *PostContent) (micropub.Properties, error) {
+	props := micropub.Properties{}
+
+	if content.Title != nil && *content.Title != "" {
+		props["name"] = []interface{}{*content.Title}
+	}
+	if content.Content != nil {
+		props["content"] = []interface{}{*content.Content}
+	}
+	if content.Excerpt != nil && *content.Excerpt != "" {
+		props["summary"] = []interface{}{*content.Excerpt}
+	}
+
+	props["post-status"] = []interface{}{s.contentStatus(nil, content)}
+
+	if date := contentDate(content); !date.IsZero() {
+		props["published"] = []interface{}{date.In(s.config.Location).Format(time.RFC3339)}
+	}
+
+	if content.Name != nil && *content.Name != "" {
+		props["mp-slug"] = []interface{}{*content.Name}
+	}
+
+	if content.Type != nil && *content.Type == "page" {
+		props[s.config.PageProperty] = []interface{}{s.config.PageValue}
+	}
+
+	values, _, _, err := s.contentCategories(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range values {
+		props["category"] = append(props["category"], c)
+	}
+
+	prop, err := enclosureProperty(content.Enclosure)
+	if err != nil {
+		return nil, err
+	}
+	if prop != "" {
+		props[prop] = []interface{}{content.Enclosure.URL}
+	}
+
+	return props, nil
+}
+
+// ErrUnsupportedEnclosure is returned for enclosures Micropub has no property
+// for, rather than dropping them.
+var ErrUnsupportedEnclosure = &xmlrpc.FaultError{StatusCode: http.StatusBadRequest, Text: "enclosures must be images, audio or video"}
+
+// enclosureProperties map the top-level MIME types of enclosures to the
+// Micropub properties they're sent as.
+var enclosureProperties = map[string]string{
+	"image": "photo",
+	"audio": "audio",
+	"video": "video",
+}
+
+// enclosureProperty returns the Micropub property an enclosure is sent as, or
+// "" if there's no enclosure. Enclosures without a type are typed by the
+// extension of their URL.
+func enclosureProperty(enclosure *Enclosure) (string, error) {
+	if enclosure == nil || enclosure.URL == "" {
+		return "", nil
+	}
+
+	contentType := enclosure.Type
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(enclosure.URL))
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if prop, ok := enclosureProperties[strings.SplitN(mediaType, "/", 2)[0]]; ok {
+			return prop, nil
+		}
+	}
+
+	return "", ErrUnsupportedEnclosure
+}
+
+// itemEnclosure returns the first audio or video file of an item as a
+// WordPress enclosure, which is how podcast clients expect to find it.
+func itemEnclosure(item *micropub.Item) Enclosure {
+	for _, prop := range []string{"audio", "video"} {
+		if url := first(item.Properties.Strings(prop)); url != "" {
+			return Enclosure{URL: url, Type: mime.TypeByExtension(path.Ext(url))}
+		}
+	}
+	return Enclosure{}
+}
+
+// itemSlug returns the slug of an item: its mp-slug if the server reports
+// one, or else the last segment of its URL's path, without any extension.
+func itemSlug(item *micropub.Item) string {
+	if slug := first(item.Properties.Strings("mp-slug")); slug != "" {
+		return slug
+	}
+
+	u, err := url.Parse(first(item.Properties.URL))
+	if err != nil {
+		return ""
+	}
+	slug := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if slug == "." || slug == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(slug, path.Ext(slug))
+}
+
+// parsePublished parses the published property of an item. Values without a
+// zone are taken to be in loc.
+func parsePublished(published string, loc *time.Location) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, published); err == nil {
+		return date, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", published, loc)
+}
+
+// contentDate returns the publish date requested by the client, preferring
+// post_date_gmt over post_date, or the zero time if neither was sent.
+func contentDate(content *PostContent) time.Time {
+	if content.DateGMT != nil && !content.DateGMT.IsZero() {
+		return *content.DateGMT
+	}
+	if content.Date != nil {
+		return *content.Date
+	}
+	return time.Time{}
+}
+
+// ErrTagsNotSupported is returned for tags sent without TAG_PREFIX set, as
+// they couldn't be told apart from categories afterwards.
+var ErrTagsNotSupported = &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "tags aren't supported without TAG_PREFIX"}
+
+// contentCategories returns the Micropub category values for the categories
+// and tags referenced by a post's terms, terms_names and mt_keywords, without
+// duplicates, and whether the client sent its categories and its tags at all.
+func (s *WPService) contentCategories(content *PostContent) (values []string, categories, tags bool, err error) {
+	var names, tagNames []string
+
+	for _, terms := range []*PostTerms{content.Terms, content.TermsNames} {
+		if terms == nil {
+			continue
+		}
+		if terms.Category != nil {
+			categories = true
+		}
+		if terms.PostTag != nil {
+			tags = true
+		}
+	}
+
+	if content.Terms != nil {
+		names = append(names, s.termNames(kindCategory, content.Terms.Category)...)
+		tagNames = append(tagNames, s.termNames(kindTag, content.Terms.PostTag)...)
+	}
+	if content.TermsNames != nil {
+		names = append(names, content.TermsNames.Category...)
+		tagNames = append(tagNames, content.TermsNames.PostTag...)
+	}
+	if content.Keywords != nil {
+		tags = true
+		for _, k := range strings.Split(*content.Keywords, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				tagNames = append(tagNames, k)
+			}
+		}
+	}
+
+	if s.config.TagPrefix == "" {
+		if len(tagNames) > 0 {
+			return nil, false, false, ErrTagsNotSupported
+		}
+		tags = false
+	}
+	for _, name := range tagNames {
+		names = append(names, s.tagValue(name))
+	}
+
+	values = []string{}
+	for _, name := range names {
+		if !contains(values, name) {
+			values = append(values, name)
+		}
+	}
+
+	return values, categories, tags, nil
+}
+
+// updateFromContent diffs the content of a wp.editPost call against the
+// current upstream item and returns the Micropub update that brings the item
+// in line with it. Members the client didn't send are left alone, and so are
+// the categories or tags of the item if the client only sent the other.
+func (s *WPService) updateFromContent(client *micropub.Client, item *micropub.Item, content *PostContent) (*micropub.Update, error) {
+	update := &micropub.Update{
+		Replace: micropub.Properties{},
+		Add:     micropub.Properties{},
+		Delete:  micropub.Properties{},
+	}
+	props := &item.Properties
+
+	if content.Title != nil {
+		old := first(props.Name)
+		if *content.Title == "" && old != "" {
+			update.DeleteProperties = append(update.DeleteProperties, "name")
+		} else if *content.Title != old {
+			update.Replace["name"] = []interface{}{*content.Title}
+		}
+	}
+
+	if content.Content != nil && *content.Content != first(props.Content) {
+		update.Replace["content"] = []interface{}{*content.Content}
+	}
+
+	if content.Excerpt != nil {
+		old := first(props.Strings("summary"))
+		if *content.Excerpt == "" && old != "" {
+			update.DeleteProperties = append(update.DeleteProperties, "summary")
+		} else if *content.Excerpt != old {
+			update.Replace["summary"] = []interface{}{*content.Excerpt}
+		}
+	}
+
+	if content.Status != nil && *content.Status != "" {
+		status := s.contentStatus(item, content)
+		if status != first(props.PostStatus) {
+			update.Replace["post-status"] = []interface{}{status}
+		}
+	}
+
+	if date := contentDate(content); !date.IsZero() {
+		old, err := parsePublished(first(props.Published), s.config.Location)
+		if err != nil || !old.Equal(date) {
+			update.Replace["published"] = []interface{}{date.In(s.config.Location).Format(time.RFC3339)}
+		}
+	}
+
+	values, categories, tags, err := s.contentCategories(content)
+	if err != nil {
+		return nil, err
+	}
+	if categories || tags {
+		if !categories || !tags {
+			split := s.termSplitter()
+			for _, c := range props.Category {
+				if _, tag := split.split(c); tag != tags && !contains(values, c) {
+					values = append(values, c)
+				}
+			}
+		}
+
+		for _, c := range values {
+			if !contains(props.Category, c) {
+				update.Add["category"] = append(update.Add["category"], c)
+			}
+		}
+		for _, c := range props.Category {
+			if !contains(values, c) {
+				update.Delete["category"] = append(update.Delete["category"], c)
+			}
+		}
+	}
+
+	prop, err := enclosureProperty(content.Enclosure)
+	if err != nil {
+		return nil, err
+	}
+	if prop != "" && !contains(props.Strings(prop), content.Enclosure.URL) {
+		update.Add[prop] = []interface{}{content.Enclosure.URL}
+	}
+
+	return update, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
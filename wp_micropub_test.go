@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+func testService() *WPService {
+	return &WPService{config: &Config{
+		Location:     time.UTC,
+		PageProperty: "post-type",
+		PageValue:    "page",
+		ScheduleMode: scheduleLocal,
+	}}
+}
+
+// testServiceWithStores is like testService, with a registry and a schedule
+// kept in a temporary directory.
+func testServiceWithStores(t *testing.T) *WPService {
+	dir := t.TempDir()
+	s := testService()
+
+	var err error
+	if s.ids, err = OpenRegistry(filepath.Join(dir, "ids.json")); err != nil {
+		t.Fatal(err)
+	}
+	if s.schedule, err = OpenSchedule(filepath.Join(dir, "schedule.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestEnclosureProperties(t *testing.T) {
+	tests := []struct {
+		name      string
+		enclosure *Enclosure
+		prop      string
+		err       error
+	}{
+		{"none", nil, "", nil},
+		{"no URL", &Enclosure{Type: "audio/mpeg"}, "", nil},
+		{"image", &Enclosure{URL: "https://example.com/a.jpg", Type: "image/jpeg"}, "photo", nil},
+		{"audio", &Enclosure{URL: "https://example.com/a.mp3", Type: "audio/mpeg", Length: 1234}, "audio", nil},
+		{"video", &Enclosure{URL: "https://example.com/a.mp4", Type: "video/mp4"}, "video", nil},
+		{"type parameters", &Enclosure{URL: "https://example.com/a.ogg", Type: "audio/ogg; codecs=opus"}, "audio", nil},
+		{"type from URL", &Enclosure{URL: "https://example.com/a.png"}, "photo", nil},
+		{"unsupported type", &Enclosure{URL: "https://example.com/a.pdf", Type: "application/pdf"}, "", ErrUnsupportedEnclosure},
+		{"unknown type", &Enclosure{URL: "https://example.com/a"}, "", ErrUnsupportedEnclosure},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			description := "Hello"
+			content := (&MetaWeblogContent{Description: &description, Enclosure: test.enclosure}).postContent(true)
+
+			props, err := testService().propertiesFromContent(content)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+
+			for _, prop := range []string{"photo", "audio", "video"} {
+				var want []interface{}
+				if prop == test.prop {
+					want = []interface{}{test.enclosure.URL}
+				}
+				if got := props[prop]; !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %v, want %v", prop, got, want)
+				}
+			}
+		})
+	}
+}
+
+func testItem(t *testing.T, properties string) *micropub.Item {
+	var item micropub.Item
+	if err := json.Unmarshal([]byte(`{"type":"h-entry","properties":`+properties+`}`), &item); err != nil {
+		t.Fatal(err)
+	}
+	return &item
+}
+
+func TestUpdateFromContentEnclosure(t *testing.T) {
+	tests := []struct {
+		name      string
+		enclosure *Enclosure
+		want      micropub.Properties
+		err       error
+	}{
+		{"none", nil, micropub.Properties{}, nil},
+		{"unchanged", &Enclosure{URL: "https://example.com/a.mp3", Type: "audio/mpeg"}, micropub.Properties{}, nil},
+		{
+			"new",
+			&Enclosure{URL: "https://example.com/b.mp3", Type: "audio/mpeg"},
+			micropub.Properties{"audio": {"https://example.com/b.mp3"}},
+			nil,
+		},
+		{
+			"new photo",
+			&Enclosure{URL: "https://example.com/b.jpg", Type: "image/jpeg"},
+			micropub.Properties{"photo": {"https://example.com/b.jpg"}},
+			nil,
+		},
+		{"unsupported", &Enclosure{URL: "https://example.com/b.zip", Type: "application/zip"}, nil, ErrUnsupportedEnclosure},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := testItem(t, `{"url":["https://example.com/1"],"audio":["https://example.com/a.mp3"]}`)
+
+			update, err := testService().updateFromContent(nil, item, &PostContent{Enclosure: test.enclosure})
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(update.Add, test.want) {
+				t.Errorf("got %v, want %v", update.Add, test.want)
+			}
+		})
+	}
+}
+
+func TestItemEnclosure(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties string
+		want       Enclosure
+	}{
+		{"none", `{}`, Enclosure{}},
+		{"photo only", `{"photo":["https://example.com/a.jpg"]}`, Enclosure{}},
+		{"audio", `{"audio":["https://example.com/a.mp3"]}`, Enclosure{URL: "https://example.com/a.mp3", Type: "audio/mpeg"}},
+		{
+			"audio before video",
+			`{"video":["https://example.com/a.mp4"],"audio":["https://example.com/a.mp3"]}`,
+			Enclosure{URL: "https://example.com/a.mp3", Type: "audio/mpeg"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := itemEnclosure(testItem(t, test.properties)); got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestContentCategories(t *testing.T) {
+	keywords := func(s string) *string { return &s }
+
+	tests := []struct {
+		name       string
+		prefix     string
+		content    PostContent
+		values     []string
+		categories bool
+		tags       bool
+		err        error
+	}{
+		{"nothing", "#", PostContent{}, []string{}, false, false, nil},
+		{
+			"categories",
+			"#",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Travel", "Food", "Travel"}}},
+			[]string{"Travel", "Food"},
+			true, false, nil,
+		},
+		{
+			"tags",
+			"#",
+			PostContent{TermsNames: &PostTerms{PostTag: []string{"beach"}}, Keywords: keywords("sun, beach,")},
+			[]string{"#beach", "#sun"},
+			false, true, nil,
+		},
+		{
+			"both",
+			"#",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Travel"}, PostTag: []string{"beach"}}},
+			[]string{"Travel", "#beach"},
+			true, true, nil,
+		},
+		{"cleared tags", "#", PostContent{Keywords: keywords("")}, []string{}, false, true, nil},
+		{
+			"no prefix, categories",
+			"",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Travel"}}, Keywords: keywords("")},
+			[]string{"Travel"},
+			true, false, nil,
+		},
+		{
+			"no prefix, tags",
+			"",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Travel"}}, Keywords: keywords("beach")},
+			nil, false, false, ErrTagsNotSupported,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testService()
+			s.config.TagPrefix = test.prefix
+
+			values, categories, tags, err := s.contentCategories(&test.content)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(values, test.values) || categories != test.categories || tags != test.tags {
+				t.Errorf("got (%v, %v, %v), want (%v, %v, %v)", values, categories, tags, test.values, test.categories, test.tags)
+			}
+		})
+	}
+}
+
+func TestUpdateFromContentCategories(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		content PostContent
+		add     micropub.Properties
+		delete  micropub.Properties
+	}{
+		{
+			"categories keep tags",
+			"#",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Food"}}},
+			micropub.Properties{"category": {"Food"}},
+			micropub.Properties{"category": {"Travel"}},
+		},
+		{
+			"tags keep categories",
+			"#",
+			PostContent{TermsNames: &PostTerms{PostTag: []string{"sun"}}},
+			micropub.Properties{"category": {"#sun"}},
+			micropub.Properties{"category": {"#beach"}},
+		},
+		{
+			"both replace all",
+			"#",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Travel"}, PostTag: []string{}}},
+			micropub.Properties{},
+			micropub.Properties{"category": {"#beach"}},
+		},
+		{
+			"no prefix, categories replace all",
+			"",
+			PostContent{TermsNames: &PostTerms{Category: []string{"Food"}}},
+			micropub.Properties{"category": {"Food"}},
+			micropub.Properties{"category": {"Travel", "#beach"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testService()
+			s.config.TagPrefix = test.prefix
+			item := testItem(t, `{"url":["https://example.com/1"],"category":["Travel","#beach"]}`)
+
+			update, err := s.updateFromContent(nil, item, &test.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(update.Add, test.add) {
+				t.Errorf("add: got %v, want %v", update.Add, test.add)
+			}
+			if !reflect.DeepEqual(update.Delete, test.delete) {
+				t.Errorf("delete: got %v, want %v", update.Delete, test.delete)
+			}
+		})
+	}
+}
+
+func TestItemSlug(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties string
+		want       string
+	}{
+		{"mp-slug", `{"mp-slug":["about-me"],"url":["https://example.com/about"]}`, "about-me"},
+		{"URL", `{"url":["https://example.com/2020/01/02/hello-world.html"]}`, "hello-world"},
+		{"trailing slash", `{"url":["https://example.com/about/"]}`, "about"},
+		{"site root", `{"url":["https://example.com/"]}`, ""},
+		{"no URL", `{}`, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := itemSlug(testItem(t, test.properties)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/codykrieger/microbridge/micropub"
+	"github.com/codykrieger/microbridge/xmlrpc"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrPagesNotSupported = &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "micropub server does not support pages"}
+
+// pagesSupported reports whether pages should be offered to clients: either
+// the server advertises a page post type, or the page convention was
+// configured explicitly.
+func (s *WPService) pagesSupported(client *micropub.Client) (bool, error) {
+	if s.config.PagesEnabled {
+		return true, nil
+	}
+
+	config, err := s.micropubConfig(client)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range config.PostTypes {
+		if t.Type == "page" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// postType returns the WordPress post type of an item.
+func (s *WPService) postType(item *micropub.Item) string {
+	if contains(item.Properties.Strings(s.config.PageProperty), s.config.PageValue) {
+		return "page"
+	}
+	return "post"
+}
+
+// postContent translates the content of a wp.newPage or wp.editPage call
+// into the equivalent wp.newPost/wp.editPost content. If the client didn't
+// send a page status, a set publish flag stands for "publish"; otherwise the
+// status is left unset, which wp.newPage takes to mean a draft and
+// wp.editPage leaves alone.
+func (c *PageContent) postContent(publish bool) *PostContent {
+	postType := "page"
+	content := &PostContent{
+		Type:    &postType,
+		Title:   c.Title,
+		Content: c.Description,
+		Date:    c.DateCreated,
+		DateGMT: c.DateCreatedGMT,
+		Status:  c.Status,
+		Name:    c.Slug,
+	}
+
+	if content.Status == nil && publish {
+		status := "publish"
+		content.Status = &status
+	}
+
+	return content
+}
+
+// pageFromPost translates a WordPress post of type "page" into the struct
+// returned by the legacy page methods.
+func pageFromPost(post *Post) Page {
+	categories := []string{}
+	for _, term := range post.Terms {
+		if term.Taxonomy == taxonomyCategory {
+			categories = append(categories, term.Name)
+		}
+	}
+
+	return Page{
+		DateCreated:       post.Date,
+		UserID:            post.Author,
+		PageID:            post.PostID,
+		Status:            post.Status,
+		Description:       post.Content,
+		Title:             post.Title,
+		Link:              post.Link,
+		PermaLink:         post.Link,
+		Categories:        categories,
+		Slug:              post.Name,
+		Author:            "You",
+		AuthorID:          post.Author,
+		AuthorDisplayName: "You",
+		DateCreatedGMT:    post.Date.UTC(),
+		CustomFields:      []CustomField{},
+		Template:          "default",
+	}
+}
+
+// findPage returns the page with the given ID, or nil if there is none or the
+// item isn't a page.
+func (s *WPService) findPage(client *micropub.Client, id string) (*micropub.Item, error) {
+	item, err := s.findPost(client, id)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	if s.postType(item) != "page" {
+		return nil, nil
+	}
+	return item, nil
+}
+
+type GetPagesArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Number   int
+}
+
+type GetPagesReply struct {
+	Pages []Page
+}
+
+func (s *WPService) GetPages(req *http.Request, args *GetPagesArgs, reply *GetPagesReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"n":   args.Number,
+	}).Info("---> wp.GetPages")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	reply.Pages = []Page{}
+
+	supported, err := s.pagesSupported(client)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return nil
+	}
+
+	filter := &PostFilter{PostType: "page", Number: args.Number}
+
+	posts, err := s.listPosts(client, sourceQuery(filter, true))
+	if err != nil {
+		return err
+	}
+
+	for _, post := range filterPosts(posts, filter) {
+		reply.Pages = append(reply.Pages, pageFromPost(&post))
+	}
+
+	return nil
+}
+
+type GetPageArgs struct {
+	BlogID   string
+	PageID   string
+	Username string
+	Password string
+}
+
+type GetPageReply struct {
+	Page Page
+}
+
+func (s *WPService) GetPage(req *http.Request, args *GetPageArgs, reply *GetPageReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"pid": args.PageID,
+	}).Info("---> wp.GetPage")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.findPage(client, args.PageID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return xmlrpc.ErrNotFound
+	}
+
+	split := s.termSplitter()
+
+	post, err := s.postFromItem(args.PageID, item, "page", split)
+	if err != nil {
+		return err
+	}
+
+	reply.Page = pageFromPost(&post)
+
+	return nil
+}
+
+type NewPageArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Content  PageContent
+	Publish  bool
+}
+
+type NewPageReply struct {
+	PageID string
+}
+
+func (s *WPService) NewPage(req *http.Request, args *NewPageArgs, reply *NewPageReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+	}).Info("---> wp.NewPage")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeCreate)
+	if err != nil {
+		return err
+	}
+
+	content := args.Content.postContent(args.Publish)
+	if content.Status == nil {
+		status := "draft"
+		content.Status = &status
+	}
+
+	id, err := s.newPost(client, content)
+	if err != nil {
+		return err
+	}
+
+	reply.PageID = id
+
+	return nil
+}
+
+type EditPageArgs struct {
+	BlogID   string
+	PageID   string
+	Username string
+	Password string
+	Content  PageContent
+	Publish  bool
+}
+
+type EditPageReply struct {
+	Success bool
+}
+
+func (s *WPService) EditPage(req *http.Request, args *EditPageArgs, reply *EditPageReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"pid": args.PageID,
+	}).Info("---> wp.EditPage")
+
+	content := args.Content.postContent(args.Publish)
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, editScope(content))
+	if err != nil {
+		return err
+	}
+
+	if err := s.editPost(client, args.PageID, content); err != nil {
+		return err
+	}
+
+	reply.Success = true
+
+	return nil
+}
+
+type DeletePageArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	PageID   string
+}
+
+type DeletePageReply struct {
+	Success bool
+}
+
+func (s *WPService) DeletePage(req *http.Request, args *DeletePageArgs, reply *DeletePageReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"pid": args.PageID,
+	}).Info("---> wp.DeletePage")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeDelete)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.findPage(client, args.PageID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return xmlrpc.ErrNotFound
+	}
+
+	if err := s.trashPost(client, args.PageID); err != nil {
+		return err
+	}
+
+	reply.Success = true
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPageFromPost(t *testing.T) {
+	s := testServiceWithStores(t)
+	s.config.TagPrefix = "#"
+	item := testItem(t, `{"url":["https://example.com/about.html"],"name":["About"],"post-type":["page"],"category":["Meta","#me"]}`)
+
+	post, err := s.postFromItem("1", item, "page", s.termSplitter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := pageFromPost(&post)
+
+	if page.Slug != "about" {
+		t.Errorf("slug: got %q, want %q", page.Slug, "about")
+	}
+	if want := []string{"Meta"}; !reflect.DeepEqual(page.Categories, want) {
+		t.Errorf("categories: got %v, want %v", page.Categories, want)
+	}
+}
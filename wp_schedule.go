@@ -0,0 +1,143 @@
+package main
+
+import (
+	"time"
+
+	"github.com/codykrieger/microbridge/micropub"
+	log "github.com/sirupsen/logrus"
+)
+
+// Ways of handling posts scheduled with post_status "future".
+const (
+	// scheduleUpstream passes the future published date upstream and leaves
+	// it to the Micropub server to hold the post until then.
+	scheduleUpstream = "upstream"
+	// scheduleLocal creates the post as a draft upstream and has the
+	// bridge's scheduler publish it when it comes due.
+	scheduleLocal = "local"
+)
+
+// scheduledFor returns the date a post should be published at, and whether
+// that's in the future, if the client asked for it to be scheduled. Without a
+// date in the content, the item's current published date is used. As in
+// WordPress, posts scheduled for a date that has already passed are published
+// right away.
+func (s *WPService) scheduledFor(item *micropub.Item, content *PostContent) (time.Time, bool) {
+	if content.Status == nil || *content.Status != "future" {
+		return time.Time{}, false
+	}
+
+	date := contentDate(content)
+	if date.IsZero() && item != nil {
+		date, _ = parsePublished(first(item.Properties.Published), s.config.Location)
+	}
+
+	return date, date.After(time.Now())
+}
+
+// contentStatus returns the Micropub post-status for the content of a
+// wp.newPost or wp.editPost call (item is nil for new posts). Posts scheduled
+// locally stay drafts until the scheduler publishes them.
+func (s *WPService) contentStatus(item *micropub.Item, content *PostContent) string {
+	status := "publish"
+	if content.Status != nil && *content.Status != "" {
+		status = *content.Status
+	}
+
+	if _, ok := s.scheduledFor(item, content); ok && s.config.ScheduleMode == scheduleLocal {
+		return "draft"
+	}
+
+	return micropubStatus(status)
+}
+
+// postStatus returns the WordPress status of a post, which is "future" for
+// posts waiting to be published, whether by the scheduler or upstream.
+func (s *WPService) postStatus(post *Post) string {
+	if _, ok := s.schedule.Get(post.PostID); ok {
+		return "future"
+	}
+	if post.Status == "publish" && post.DateGMT.After(time.Now()) {
+		return "future"
+	}
+	return post.Status
+}
+
+// reschedule brings the local schedule in line with the content of a
+// wp.newPost or wp.editPost call, after the item at url was created or
+// updated with client. It does nothing unless posts are scheduled locally.
+func (s *WPService) reschedule(client *micropub.Client, id, url string, item *micropub.Item, content *PostContent) error {
+	if s.config.ScheduleMode != scheduleLocal {
+		return nil
+	}
+
+	date, ok := s.scheduledFor(item, content)
+	if !ok {
+		return s.schedule.Remove(id)
+	}
+
+	log.WithFields(log.Fields{"url": url, "date": date}).Info("scheduled post")
+
+	return s.schedule.Set(id, ScheduledPost{
+		URL:         url,
+		Endpoint:    client.Endpoint,
+		Destination: client.Destination,
+		Token:       client.Token,
+		Date:        date,
+	})
+}
+
+// keepScheduled fills in post_status "future" for a wp.editPost call that
+// doesn't change the status of a locally scheduled post, so that the rest of
+// the edit treats it as still scheduled.
+func (s *WPService) keepScheduled(id string, content *PostContent) {
+	if content.Status != nil {
+		return
+	}
+	if _, ok := s.schedule.Get(id); ok {
+		status := "future"
+		content.Status = &status
+	}
+}
+
+// runScheduler publishes locally scheduled posts as they come due, checking
+// every interval. It never returns.
+func (s *WPService) runScheduler(interval time.Duration) {
+	s.publishDue(time.Now())
+	for now := range time.Tick(interval) {
+		s.publishDue(now)
+	}
+}
+
+// publishDue publishes the scheduled posts that are due. Posts the Micropub
+// server refuses to publish are dropped from the schedule; other failures
+// are retried on the next run.
+func (s *WPService) publishDue(now time.Time) {
+	for id, post := range s.schedule.Due(now) {
+		logger := log.WithFields(log.Fields{"pid": id, "url": post.URL})
+
+		client := micropub.NewClient(post.Endpoint, post.Token)
+		client.Destination = post.Destination
+		update := &micropub.Update{
+			Replace: micropub.Properties{
+				"post-status": {"published"},
+				"published":   {post.Date.In(s.config.Location).Format(time.RFC3339)},
+			},
+		}
+
+		if _, err := client.Update(post.URL, update); err != nil {
+			if e, ok := err.(*micropub.Error); ok && e.StatusCode < 500 {
+				logger.WithError(err).Error("failed to publish scheduled post; unscheduling it")
+			} else {
+				logger.WithError(err).Warn("failed to publish scheduled post; will retry")
+				continue
+			}
+		} else {
+			logger.Info("published scheduled post")
+		}
+
+		if err := s.schedule.Remove(id); err != nil {
+			logger.WithError(err).Error("failed to unschedule post")
+		}
+	}
+}
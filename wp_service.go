@@ -1,35 +1,326 @@
 package main
 
 import (
-	"fmt"
+	"bytes"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
 	"time"
 
+	"github.com/codykrieger/microbridge/indieauth"
 	"github.com/codykrieger/microbridge/micropub"
 	"github.com/codykrieger/microbridge/xmlrpc"
 	log "github.com/sirupsen/logrus"
 )
 
+var ErrNoMediaEndpoint = &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "micropub server has no media endpoint"}
+
 type WPService struct {
-	config *Config
+	config    *Config
+	ids       *Registry
+	schedule  *Schedule
+	creds     *CredentialCache
+	logins    *Logins
+	endpoints *EndpointCache
+	uploads   *Uploads
+}
+
+// account returns the account an XML-RPC call's credentials stand for.
+// Passwords issued by the login flow map to the account that signed in. Any
+// other password is taken to be a Micropub token: for the site the username
+// names, if it names one, and otherwise for the configured Micropub endpoint.
+func (s *WPService) account(username, password string) (Account, error) {
+	if account, ok := s.logins.Get(password); ok {
+		return account, nil
+	}
+
+	site, ok := siteURL(username, s.config.DiscoverHostNames)
+	if !ok {
+		return Account{
+			Me:               s.config.TokenMe,
+			MicropubEndpoint: s.config.MicropubEndpoint,
+			TokenEndpoint:    s.config.TokenEndpoint,
+			Token:            password,
+		}, nil
+	}
+
+	endpoints, err := s.endpoints.Get(site)
+	if err != nil {
+		log.WithError(err).WithField("site", site).Error("endpoint discovery failed")
+		return Account{}, &xmlrpc.FaultError{StatusCode: http.StatusBadGateway, Text: "couldn't discover the endpoints of " + site}
+	}
+	if endpoints.Micropub == "" {
+		return Account{}, &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: site + " doesn't advertise a Micropub endpoint"}
+	}
+
+	return Account{
+		Me:               endpoints.Me,
+		MicropubEndpoint: endpoints.Micropub,
+		MediaEndpoint:    endpoints.MediaEndpoint,
+		TokenEndpoint:    endpoints.TokenEndpoint,
+		Token:            password,
+	}, nil
+}
+
+// newClient returns a Micropub client for an account. Tokens the server
+// rejects are dropped from the credential cache.
+func (s *WPService) newClient(account *Account) *micropub.Client {
+	client := micropub.NewClient(account.MicropubEndpoint, account.Token)
+	client.OnError = func(err *micropub.Error) {
+		if micropub.IsAuthError(err) {
+			s.creds.Invalidate(client.Token)
+		}
+	}
+	return client
 }
 
-func (s *WPService) checkAuth(username, password string) error {
+// micropubConfig returns the Micropub config for the client's token, from the
+// credential cache if possible.
+func (s *WPService) micropubConfig(client *micropub.Client) (*micropub.Config, error) {
+	return s.creds.Config(client)
+}
+
+// IndieAuth scopes required by the methods that change things.
+const (
+	scopeCreate = "create"
+	scopeUpdate = "update"
+	scopeDelete = "delete"
+	scopeMedia  = "media"
+)
+
+// checkAuth authenticates an XML-RPC call and returns a Micropub client for
+// the account it acts as (see account), directed at the destination blogID
+// stands for. If the account has a token endpoint, the token is verified
+// against it and must have been granted the given scopes. Otherwise, the
+// Micropub server is asked for its config; Micro.blog answers even for
+// invalid tokens, but leaves out the destinations. Calls that require scopes
+// are the ones that change things, which fault for blog IDs that aren't the
+// account's rather than falling back to its default blog.
+func (s *WPService) checkAuth(blogID, username, password string, scopes ...string) (*micropub.Client, error) {
 	if username == "" || password == "" {
-		return xmlrpc.ErrForbidden
+		return nil, xmlrpc.ErrForbidden
 	}
 
-	client := micropub.NewClient(s.config.MicropubEndpoint, password)
-	config, err := client.GetConfig()
+	account, err := s.account(username, password)
 	if err != nil {
+		return nil, err
+	}
+	client := s.newClient(&account)
+
+	if account.TokenEndpoint != "" {
+		if err := s.checkToken(&account, scopes); err != nil {
+			return nil, err
+		}
+	} else {
+		config, err := s.micropubConfig(client)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(config.Destination) == 0 {
+			log.Error("micropub config contains no destinations; assuming authentication failure")
+			return nil, xmlrpc.ErrForbidden
+		}
+	}
+
+	if client.Destination, err = s.destination(client, blogID, len(scopes) > 0); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// ErrUnknownBlog is returned for calls that would change a blog other than
+// the ones the account can post to.
+var ErrUnknownBlog = &xmlrpc.FaultError{StatusCode: http.StatusNotFound, Text: "blog not found; it isn't one of this account's blogs"}
+
+// destination returns the uid of the Micropub destination a WordPress blog ID
+// stands for, or "" for the server's default destination. Blog IDs are handed
+// out by getUsersBlogs, and are shared by every account using the bridge. An
+// empty ID stands for the default. Other IDs that don't stand for one of the
+// account's blogs fault for calls that change things (write), so that a stale
+// or foreign ID can't send a post to the wrong blog; other calls fall back to
+// the default.
+func (s *WPService) destination(client *micropub.Client, blogID string, write bool) (string, error) {
+	if blogID == "" {
+		return "", nil
+	}
+
+	uid, ok := s.ids.Key(kindBlog, blogID)
+	if ok && uid == client.Endpoint {
+		// the blog of an account without destinations (see usersBlogs)
+		return "", nil
+	}
+
+	if ok {
+		config, err := s.micropubConfig(client)
+		if err != nil {
+			return "", err
+		}
+
+		for _, d := range config.Destination {
+			if d.UID == uid {
+				return uid, nil
+			}
+		}
+	}
+
+	logger := log.WithFields(log.Fields{"bid": blogID, "uid": uid})
+	if write {
+		logger.Error("blog isn't a destination of this account")
+		return "", ErrUnknownBlog
+	}
+	logger.Warn("blog isn't a destination of this account; using the default")
+	return "", nil
+}
+
+// checkPostAuth is checkAuth for calls that name a post but no blog (such as
+// metaWeblog.getPost), directed at the blog the post was seen on.
+func (s *WPService) checkPostAuth(postID, username, password string, scopes ...string) (*micropub.Client, error) {
+	blogID, _ := s.ids.Blog(postID)
+	return s.checkAuth(blogID, username, password, scopes...)
+}
+
+// checkToken verifies an account's token against its token endpoint.
+func (s *WPService) checkToken(account *Account, scopes []string) error {
+	token, err := s.creds.Token(account.TokenEndpoint, account.Token)
+	if err == indieauth.ErrInvalidToken {
+		return xmlrpc.ErrForbidden
+	} else if err != nil {
 		return err
 	}
 
-	if len(config.Destination) == 0 {
-		log.Error("micropub config contains no destinations; assuming authentication failure")
+	logger := log.WithFields(log.Fields{"me": token.Me, "client_id": token.ClientID})
+
+	if account.Me != "" && !indieauth.SameProfile(token.Me, account.Me) {
+		logger.Error("token was issued for another user")
+		return xmlrpc.ErrForbidden
+	}
+	if token.ClientID == "" {
+		logger.Error("token endpoint reported no client_id")
 		return xmlrpc.ErrForbidden
 	}
 
+	for _, scope := range scopes {
+		if !token.HasScope(scope) {
+			logger.WithField("scope", token.Scope).Errorf("token lacks %s scope", scope)
+			return &xmlrpc.FaultError{StatusCode: http.StatusUnauthorized, Text: "token lacks " + scope + " scope"}
+		}
+	}
+
+	return nil
+}
+
+var wpMethodHelp = map[string]string{
+	"GetUsersBlogs":   "Returns the blogs (Micropub destinations) the user can post to.",
+	"GetUsers":        "Returns the users of a blog. The bridge always reports a single user.",
+	"GetAuthors":      "Returns the authors of a blog.",
+	"GetCategories":   "Returns the categories known to the Micropub server.",
+	"NewCategory":     "Creates a category. It's kept by the bridge until it's used on a post, as Micropub can't create categories.",
+	"DeleteCategory":  "Deletes a category, removing it from the posts that use it.",
+	"GetPosts":        "Returns posts (or pages, with post_type \"page\"), honoring the number, offset, orderby, order and post_status filters.",
+	"GetPost":         "Returns a single post, limited to the requested fields.",
+	"NewPost":         "Creates a post with a Micropub create request and returns its ID. Posts with post_status \"future\" are published at their post_date.",
+	"EditPost":        "Updates a post with a Micropub update request. Setting post_status to \"trash\" deletes the post; setting it back restores it.",
+	"DeletePost":      "Deletes a post with a Micropub delete request.",
+	"GetPages":        "Returns pages, if the Micropub server supports them.",
+	"GetPage":         "Returns a single page.",
+	"NewPage":         "Creates a page and returns its ID.",
+	"EditPage":        "Updates a page.",
+	"DeletePage":      "Deletes a page.",
+	"GetTags":         "Returns the tags used on posts, with counts. Tags are Micropub categories the server doesn't list, or those starting with TAG_PREFIX if it's set.",
+	"GetTaxonomies":   "Returns the category and post_tag taxonomies.",
+	"GetTaxonomy":     "Returns a single taxonomy.",
+	"GetTerms":        "Returns the terms of a taxonomy, with counts, honoring the number, offset, orderby, order, hide_empty and search filters.",
+	"GetTerm":         "Returns a single term.",
+	"NewTerm":         "Creates a category, which the Micropub server learns about once it's used on a post.",
+	"EditTerm":        "Renames a category, rewriting the posts that use it.",
+	"DeleteTerm":      "Deletes a category, removing it from the posts that use it.",
+	"GetMediaLibrary": "Returns the files uploaded to the media endpoint, honoring the number, offset and mime_type filters. Servers that can't list their files only report uploads made through the bridge.",
+	"GetMediaItem":    "Returns a single file from the media library.",
+	"NewMediaObject":  "Uploads a file to the Micropub media endpoint and returns its URL.",
+	"UploadFile":      "Uploads a file to the Micropub media endpoint and returns its URL. The same as metaWeblog.newMediaObject.",
+}
+
+// Help implements xmlrpc.Helper.
+func (s *WPService) Help(method string) string {
+	return wpMethodHelp[method]
+}
+
+// usersBlogs lists the blogs the account behind username and password can
+// post to: one per Micropub destination, or just the account's site if the
+// server doesn't list destinations.
+func (s *WPService) usersBlogs(username, password string) ([]UserBlog, error) {
+	client, err := s.checkAuth("", username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := s.micropubConfig(client)
+	if err != nil {
+		return nil, err
+	}
+
+	blogs := []UserBlog{}
+	xmlrpcURL := s.config.BlogURL + "/xmlrpc"
+
+	for _, d := range config.Destination {
+		id, err := s.ids.ID(kindBlog, d.UID)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, UserBlog{
+			IsAdmin:  true,
+			URL:      d.UID,
+			BlogID:   id,
+			BlogName: d.Name,
+			XMLRPC:   xmlrpcURL,
+		})
+	}
+
+	if len(blogs) == 0 {
+		account, err := s.account(username, password)
+		if err != nil {
+			return nil, err
+		}
+		id, err := s.ids.ID(kindBlog, account.MicropubEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, UserBlog{
+			IsAdmin:  true,
+			URL:      account.Me,
+			BlogID:   id,
+			BlogName: account.Me,
+			XMLRPC:   xmlrpcURL,
+		})
+	}
+
+	return blogs, nil
+}
+
+type GetUsersBlogsArgs struct {
+	Username string
+	Password string
+}
+
+type GetUsersBlogsReply struct {
+	Blogs []UserBlog
+}
+
+func (s *WPService) GetUsersBlogs(req *http.Request, args *GetUsersBlogsArgs, reply *GetUsersBlogsReply) error {
+	log.WithFields(log.Fields{
+		"u": args.Username,
+	}).Info("---> wp.GetUsersBlogs")
+
+	blogs, err := s.usersBlogs(args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	reply.Blogs = blogs
+
 	return nil
 }
 
@@ -53,7 +344,7 @@ func (s *WPService) GetUsers(req *http.Request, args *GetUsersArgs, reply *GetUs
 		"filter": args.Filter,
 	}).Info("---> wp.GetUsers")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	if _, err := s.checkAuth(args.BlogID, args.Username, args.Password); err != nil {
 		return err
 	}
 
@@ -84,7 +375,7 @@ func (s *WPService) GetAuthors(req *http.Request, args *GetAuthorsArgs, reply *G
 		"u":   args.Username,
 	}).Info("---> wp.GetAuthors")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	if _, err := s.checkAuth(args.BlogID, args.Username, args.Password); err != nil {
 		return err
 	}
 
@@ -107,27 +398,50 @@ func (s *WPService) GetCategories(req *http.Request, args *GetCategoriesArgs, re
 		"u":   args.Username,
 	}).Info("---> wp.GetCategories")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
 		return err
 	}
 
-	client := micropub.NewClient(s.config.MicropubEndpoint, args.Password)
-
-	categories, err := client.GetCategories()
+	categories, err := s.categoryList(client)
 	if err != nil {
 		return err
 	}
 
-	reply.Categories = []Category{}
+	reply.Categories = categories
 
-	for i, v := range categories {
-		reply.Categories = append(reply.Categories, Category{
-			CategoryID: fmt.Sprintf("%d", i),
-			Name:       v,
-		})
+	return nil
+}
+
+// categoryList returns the client's categories as listed by
+// wp.getCategories and metaWeblog.getCategories.
+func (s *WPService) categoryList(client *micropub.Client) ([]Category, error) {
+	names, err := s.categories(client)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	categories := []Category{}
+
+	err = s.ids.Batch(func() error {
+		for _, v := range names {
+			id, err := s.ids.ID(kindCategory, v)
+			if err != nil {
+				return err
+			}
+
+			categories = append(categories, Category{
+				CategoryID: id,
+				Name:       v,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return categories, nil
 }
 
 type NewCategoryArgs struct {
@@ -135,7 +449,10 @@ type NewCategoryArgs struct {
 	Username string
 	Password string
 	Category struct {
-		Name string `xml:"name"`
+		Name        string `xml:"name"`
+		Slug        string `xml:"slug"`
+		ParentID    string `xml:"parent_id"`
+		Description string `xml:"description"`
 	}
 }
 
@@ -149,13 +466,49 @@ func (s *WPService) NewCategory(req *http.Request, args *NewCategoryArgs, reply
 		"u":   args.Username,
 	}).Info("---> wp.NewCategory")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeCreate)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.newCategory(client, args.Category.Name)
+	if err != nil {
+		return err
+	}
+
+	reply.CategoryID, err = strconv.Atoi(id)
+
+	return err
+}
+
+type DeleteCategoryArgs struct {
+	BlogID     string
+	Username   string
+	Password   string
+	CategoryID string
+}
+
+type DeleteCategoryReply struct {
+	Success bool
+}
+
+func (s *WPService) DeleteCategory(req *http.Request, args *DeleteCategoryArgs, reply *DeleteCategoryReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"cid": args.CategoryID,
+	}).Info("---> wp.DeleteCategory")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeUpdate)
+	if err != nil {
 		return err
 	}
 
-	// args.Category.Name
+	if err := s.deleteCategory(client, args.CategoryID); err != nil {
+		return err
+	}
 
-	reply.CategoryID = 999
+	reply.Success = true
 
 	return nil
 }
@@ -164,15 +517,8 @@ type GetPostsArgs struct {
 	BlogID   string
 	Username string
 	Password string
-	Filter   struct {
-		PostType   string `xml:"post_type"`
-		PostStatus string `xml:"post_status"`
-		Number     int    `xml:"number"`
-		Offset     int    `xml:"offset"`
-		OrderBy    string `xml:"orderby"`
-		Order      string `xml:"order"`
-	}
-	Fields []string
+	Filter   PostFilter
+	Fields   []string
 }
 
 type GetPostsReply struct {
@@ -187,67 +533,33 @@ func (s *WPService) GetPosts(req *http.Request, args *GetPostsArgs, reply *GetPo
 		"fields": args.Fields,
 	}).Info("---> wp.GetPosts")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
 		return err
 	}
 
-	if args.Filter.PostType != "post" {
-		return nil
-	}
-
-	client := micropub.NewClient(s.config.MicropubEndpoint, args.Password)
-
-	posts, err := client.GetPosts()
+	hasPages, err := s.pagesSupported(client)
 	if err != nil {
 		return err
 	}
 
-	reply.Posts = []Post{}
-
-	for _, v := range posts {
-		status := v.Properties.PostStatus[0]
-		wpStatus := ""
-		switch status {
-		case "published":
-			wpStatus = "publish"
-		case "draft":
-			wpStatus = "draft"
-		default:
-			log.Warnf("unknown micropub post status '%s'", status)
-			wpStatus = "publish"
-		}
-
-		dateString := v.Properties.Published[0]
-		date, err := time.Parse(time.RFC3339, dateString)
-		if err != nil {
-			return err
+	switch args.Filter.PostType {
+	case "", "post":
+	case "page":
+		if !hasPages {
+			return nil
 		}
+	default:
+		return nil
+	}
 
-		date = date.Local()
-		log.WithField("d", date).Infof("parsed date (%s)", dateString)
-
-		reply.Posts = append(reply.Posts, Post{
-			PostID:        fmt.Sprintf("%d", v.Properties.UID[0]),
-			Title:         v.Properties.Name[0],
-			Date:          date,
-			DateModified:  date,
-			Status:        wpStatus,
-			Type:          "post",
-			Format:        "standard",
-			Name:          "",
-			Author:        "1",
-			Content:       v.Properties.Content[0],
-			Parent:        "0",
-			MIMEType:      "text/plain",
-			Link:          v.Properties.URL[0],
-			CommentStatus: "closed",
-			PingStatus:    "closed",
-			Sticky:        false,
-			Terms:         []Term{},
-			CustomFields:  []CustomField{},
-		})
+	posts, err := s.listPosts(client, sourceQuery(&args.Filter, hasPages))
+	if err != nil {
+		return err
 	}
 
+	reply.Posts = filterPosts(posts, &args.Filter)
+
 	return nil
 }
 
@@ -256,7 +568,7 @@ type EditPostArgs struct {
 	Username string
 	Password string
 	PostID   string
-	Content  Post
+	Content  PostContent
 }
 
 type EditPostReply struct {
@@ -270,29 +582,79 @@ func (s *WPService) EditPost(req *http.Request, args *EditPostArgs, reply *EditP
 		"pid": args.PostID,
 	}).Info("---> wp.EditPost")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, editScope(&args.Content))
+	if err != nil {
 		return err
 	}
 
-	// args.Content.Title
-	// args.Content.Status
-	// args.Content.Content
-	// args.Content.Date
-	// args.Content.Terms (categories)
-	// args.Content.TermsNames (tags)
-	// args.Content.Name (?)
-	// args.Content.Enclosure (optional; don't need this yet)
+	if err := s.editPost(client, args.PostID, &args.Content); err != nil {
+		return err
+	}
 
 	reply.Success = true
 
 	return nil
 }
 
+// isTrash reports whether content moves a post to the trash.
+func isTrash(content *PostContent) bool {
+	return content.Status != nil && *content.Status == "trash"
+}
+
+// editScope returns the scope needed to apply content to a post.
+func editScope(content *PostContent) string {
+	if isTrash(content) {
+		return scopeDelete
+	}
+	return scopeUpdate
+}
+
+// editPost applies the content of an edit call to the post with the given ID.
+func (s *WPService) editPost(client *micropub.Client, id string, content *PostContent) error {
+	if isTrash(content) {
+		return s.trashPost(client, id)
+	}
+
+	var item *micropub.Item
+	var err error
+	if s.ids.IsTrashed(id) && content.Status != nil && *content.Status != "" {
+		// Moving a post out of the trash restores it before applying any
+		// other changes, whether or not the server still serves it. Edits
+		// that leave the status alone don't.
+		item, err = s.restorePost(client, id)
+	} else {
+		item, err = s.findPost(client, id)
+	}
+	if err != nil {
+		return err
+	}
+	if item == nil || len(item.Properties.URL) == 0 {
+		return xmlrpc.ErrNotFound
+	}
+	if content.Type != nil && *content.Type == "page" && s.postType(item) != "page" {
+		return xmlrpc.ErrNotFound
+	}
+
+	s.keepScheduled(id, content)
+	update, err := s.updateFromContent(client, item, content)
+	if err != nil {
+		return err
+	}
+
+	if !update.Empty() {
+		if _, err := client.Update(item.Properties.URL[0], update); err != nil {
+			return err
+		}
+	}
+
+	return s.reschedule(client, id, item.Properties.URL[0], item, content)
+}
+
 type NewPostArgs struct {
 	BlogID   string
 	Username string
 	Password string
-	Content  Post
+	Content  PostContent
 }
 
 type NewPostReply struct {
@@ -305,24 +667,58 @@ func (s *WPService) NewPost(req *http.Request, args *NewPostArgs, reply *NewPost
 		"u":   args.Username,
 	}).Info("---> wp.NewPost")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeCreate)
+	if err != nil {
 		return err
 	}
 
-	// args.Content.Title
-	// args.Content.Status
-	// args.Content.Content
-	// args.Content.Date (optional, apparently)
-	// args.Content.Terms (categories)
-	// args.Content.TermsNames (tags)
-	// args.Content.Name (?)
-	// args.Content.Enclosure (optional; don't need this yet)
+	id, err := s.newPost(client, &args.Content)
+	if err != nil {
+		return err
+	}
 
-	reply.PostID = "999"
+	reply.PostID = id
 
 	return nil
 }
 
+// newPost creates a post from the content of a create call and returns its
+// ID.
+func (s *WPService) newPost(client *micropub.Client, content *PostContent) (string, error) {
+	if content.Type != nil && *content.Type == "page" {
+		supported, err := s.pagesSupported(client)
+		if err != nil {
+			return "", err
+		}
+		if !supported {
+			return "", ErrPagesNotSupported
+		}
+	}
+
+	props, err := s.propertiesFromContent(content)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Create(props)
+	if err != nil {
+		return "", err
+	}
+
+	log.WithField("url", result.URL).Info("created post")
+
+	id, err := s.urlPostID(client, result.URL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.reschedule(client, id, result.URL, nil, content); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
 type GetPostArgs struct {
 	BlogID   string
 	Username string
@@ -332,21 +728,72 @@ type GetPostArgs struct {
 }
 
 type GetPostReply struct {
-	Post Post
+	Post xmlrpc.Struct
 }
 
 func (s *WPService) GetPost(req *http.Request, args *GetPostArgs, reply *GetPostReply) error {
+	log.WithFields(log.Fields{
+		"bid":    args.BlogID,
+		"u":      args.Username,
+		"pid":    args.PostID,
+		"fields": args.Fields,
+	}).Info("---> wp.GetPost")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.findPost(client, args.PostID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return xmlrpc.ErrNotFound
+	}
+
+	split := s.termSplitter()
+
+	post, err := s.postFromItem(args.PostID, item, s.postType(item), split)
+	if err != nil {
+		return err
+	}
+
+	reply.Post = postFields(&post, args.Fields)
+
+	return nil
+}
+
+type DeletePostArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	PostID   string
+}
+
+type DeletePostReply struct {
+	Success bool
+}
+
+func (s *WPService) DeletePost(req *http.Request, args *DeletePostArgs, reply *DeletePostReply) error {
 	log.WithFields(log.Fields{
 		"bid": args.BlogID,
 		"u":   args.Username,
 		"pid": args.PostID,
-	}).Info("---> wp.GetPost")
+	}).Info("---> wp.DeletePost")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeDelete)
+	if err != nil {
+		return err
+	}
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	if err := s.trashPost(client, args.PostID); err != nil {
 		return err
 	}
 
-	return xmlrpc.ErrNotImplemented
+	reply.Success = true
+
+	return nil
 }
 
 type GetTagsArgs struct {
@@ -365,10 +812,26 @@ func (s *WPService) GetTags(req *http.Request, args *GetTagsArgs, reply *GetTags
 		"u":   args.Username,
 	}).Info("---> wp.GetTags")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	terms, err := s.terms(client, taxonomyPostTag)
+	if err != nil {
 		return err
 	}
 
+	reply.Tags = []Tag{}
+	for _, term := range terms {
+		reply.Tags = append(reply.Tags, Tag{
+			ID:    term.ID,
+			Name:  term.Name,
+			Count: term.Count,
+			Slug:  term.Slug,
+		})
+	}
+
 	return nil
 }
 
@@ -384,6 +847,7 @@ type NewMediaObjectArgs struct {
 }
 
 type NewMediaObjectReply struct {
+	Media MediaObject
 }
 
 func (s *WPService) NewMediaObject(req *http.Request, args *NewMediaObjectArgs, reply *NewMediaObjectReply) error {
@@ -392,11 +856,74 @@ func (s *WPService) NewMediaObject(req *http.Request, args *NewMediaObjectArgs,
 		"u":   args.Username,
 	}).Info("---> metaWeblog.newMediaObject")
 
-	if err := s.checkAuth(args.Username, args.Password); err != nil {
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeMedia)
+	if err != nil {
 		return err
 	}
 
 	log.Infof("object: %s; type: %s", args.Object.Name, args.Object.Type)
 
+	mediaEndpoint, err := s.mediaEndpoint(client, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	data := []byte(args.Object.Bits)
+	name := path.Base(args.Object.Name)
+
+	contentType := args.Object.Type
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	result, err := client.UploadMedia(mediaEndpoint, name, contentType, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	location := result.URL
+
+	log.WithField("url", location).Info("uploaded media")
+
+	id, err := s.ids.ID(kindMedia, location)
+	if err != nil {
+		return err
+	}
+
+	account, err := s.account(args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.uploads.Add(id, Upload{
+		Owner:       uploadOwner(&account),
+		URL:         location,
+		Name:        name,
+		Type:        contentType,
+		Endpoint:    mediaEndpoint,
+		Destination: client.Destination,
+		Date:        time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	reply.Media = MediaObject{
+		ID:   id,
+		File: name,
+		URL:  location,
+		Type: contentType,
+	}
+
 	return nil
 }
+
+// UploadFile implements wp.uploadFile, which takes the same arguments as
+// metaWeblog.newMediaObject. The overwrite member of the file struct is
+// ignored.
+func (s *WPService) UploadFile(req *http.Request, args *NewMediaObjectArgs, reply *NewMediaObjectReply) error {
+	return s.NewMediaObject(req, args, reply)
+}
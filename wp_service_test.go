@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codykrieger/microbridge/micropub"
+)
+
+// newConfigServer returns a Micropub server whose config lists the given
+// destinations.
+func newConfigServer(t *testing.T, config string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(config))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDestination(t *testing.T) {
+	server := newConfigServer(t, `{"destination":[{"uid":"https://a.example/","name":"A"},{"uid":"https://b.example/","name":"B"}]}`)
+
+	ids, err := OpenRegistry(filepath.Join(t.TempDir(), "ids.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testService()
+	s.ids = ids
+	s.creds = NewCredentialCache(time.Minute)
+
+	blog := map[string]string{}
+	for _, key := range []string{"https://a.example/", "https://b.example/", "https://other.example/", server.URL} {
+		if blog[key], err = ids.ID(kindBlog, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		blogID string
+		write  bool
+		want   string
+		err    error
+	}{
+		{"empty", "", true, "", nil},
+		{"destination", blog["https://b.example/"], true, "https://b.example/", nil},
+		{"destination read", blog["https://a.example/"], false, "https://a.example/", nil},
+		{"account's site", blog[server.URL], true, "", nil},
+		{"foreign blog", blog["https://other.example/"], true, "", ErrUnknownBlog},
+		{"foreign blog read", blog["https://other.example/"], false, "", nil},
+		{"unknown blog", "99", true, "", ErrUnknownBlog},
+		{"unknown blog read", "99", false, "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := micropub.NewClient(server.URL, "token")
+			got, err := s.destination(client, test.blogID, test.write)
+			if got != test.want || err != test.err {
+				t.Errorf("got %q, %v; want %q, %v", got, err, test.want, test.err)
+			}
+		})
+	}
+}
+
+func TestEditPostRestoresTrashed(t *testing.T) {
+	const postURL = "https://example.com/1"
+
+	tests := []struct {
+		name        string
+		trashed     bool
+		status      string
+		want        []string
+		wantTrashed bool
+	}{
+		{"restore", true, "publish", []string{"undelete", "update"}, false},
+		{"restore as draft", true, "draft", []string{"undelete", "update"}, false},
+		{"edit in trash", true, "", []string{"update"}, true},
+		{"publish", false, "publish", []string{"update"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The server keeps serving deleted posts, as some do.
+			var actions []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"properties":{"url":["` + postURL + `"],"content":["Hello"],"post-status":["published"]}}`))
+					return
+				}
+				var body struct {
+					Action string `json:"action"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Error(err)
+				}
+				actions = append(actions, body.Action)
+			}))
+			defer server.Close()
+
+			s := testServiceWithStores(t)
+
+			id, err := s.ids.ID(kindPost, postURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.ids.SetTrashed(id, test.trashed); err != nil {
+				t.Fatal(err)
+			}
+
+			title := "Edited"
+			content := &PostContent{Title: &title}
+			if test.status != "" {
+				content.Status = &test.status
+			}
+			if err := s.editPost(micropub.NewClient(server.URL, "token"), id, content); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(actions, test.want) {
+				t.Errorf("got actions %v, want %v", actions, test.want)
+			}
+			if got := s.ids.IsTrashed(id); got != test.wantTrashed {
+				t.Errorf("trashed: got %v, want %v", got, test.wantTrashed)
+			}
+		})
+	}
+}
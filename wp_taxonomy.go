@@ -0,0 +1,716 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/codykrieger/microbridge/micropub"
+	"github.com/codykrieger/microbridge/xmlrpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// The taxonomies the bridge exposes. Micropub only knows categories, so the
+// terms of both are Micropub category values, told apart by a termSplitter.
+const (
+	taxonomyCategory = "category"
+	taxonomyPostTag  = "post_tag"
+)
+
+var ErrInvalidTaxonomy = &xmlrpc.FaultError{StatusCode: http.StatusForbidden, Text: "invalid taxonomy"}
+
+var taxonomies = []Taxonomy{
+	{
+		Name:         taxonomyCategory,
+		Label:        "Categories",
+		Hierarchical: true,
+		Public:       true,
+		ShowUI:       true,
+		Builtin:      true,
+		Labels:       map[string]string{"name": "Categories", "singular_name": "Category"},
+		Cap:          taxonomyCaps("categories"),
+		ObjectType:   []string{"post"},
+	},
+	{
+		Name:         taxonomyPostTag,
+		Label:        "Tags",
+		Hierarchical: false,
+		Public:       true,
+		ShowUI:       true,
+		Builtin:      true,
+		Labels:       map[string]string{"name": "Tags", "singular_name": "Tag"},
+		Cap:          taxonomyCaps("post_tags"),
+		ObjectType:   []string{"post"},
+	},
+}
+
+func taxonomyCaps(what string) map[string]string {
+	return map[string]string{
+		"manage_terms": "manage_" + what,
+		"edit_terms":   "manage_" + what,
+		"delete_terms": "manage_" + what,
+		"assign_terms": "edit_posts",
+	}
+}
+
+func findTaxonomy(name string) (*Taxonomy, bool) {
+	for i := range taxonomies {
+		if taxonomies[i].Name == name {
+			return &taxonomies[i], true
+		}
+	}
+	return nil, false
+}
+
+// slugify derives a WordPress-style slug from a term name.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// catalog names the set of categories a client works with: those of one
+// destination of one Micropub server.
+func catalog(client *micropub.Client) string {
+	return client.Endpoint + " " + client.Destination
+}
+
+// categories returns the names of the categories the Micropub server lists,
+// except for prefixed tags, followed by those created by clients but not used
+// on any post yet. Pending categories the server has since learned about are
+// no longer pending.
+func (s *WPService) categories(client *micropub.Client) ([]string, error) {
+	values, err := client.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, v := range values {
+		if s.config.TagPrefix == "" || !strings.HasPrefix(v, s.config.TagPrefix) {
+			names = append(names, v)
+		}
+	}
+
+	pending := []string{}
+	for _, id := range s.ids.Pending(catalog(client)) {
+		name, ok := s.ids.Key(kindCategory, id)
+		if !ok || contains(names, name) {
+			if err := s.ids.SetPending(catalog(client), id, false); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		pending = append(pending, name)
+	}
+	sort.Strings(pending)
+
+	return append(names, pending...), nil
+}
+
+// newCategory returns the ID of the named category, adding it to the
+// client's catalog as pending if the Micropub server doesn't know it yet.
+func (s *WPService) newCategory(client *micropub.Client, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", &xmlrpc.FaultError{StatusCode: http.StatusForbidden, Text: "the category name cannot be empty"}
+	}
+
+	names, err := s.categories(client)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := s.ids.ID(kindCategory, name)
+	if err != nil {
+		return "", err
+	}
+
+	if !contains(names, name) {
+		log.WithField("name", name).Info("created pending category")
+		if err := s.ids.SetPending(catalog(client), id, true); err != nil {
+			return "", err
+		}
+	}
+
+	return id, nil
+}
+
+// Faults for category changes the Micropub server can't be made to reflect.
+var (
+	ErrCategoriesNotReported = &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "micropub server doesn't report the categories of posts, so they can't be rewritten"}
+	ErrCategoryUnused        = &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "the category isn't used on any post, so the micropub server can't change it"}
+)
+
+// replaceCategory replaces the category old with new on every post that has
+// it, or just removes it if new is empty. Servers only learn about category
+// changes through posts, so it fails if no post was rewritten, including when
+// the server doesn't report the categories of posts (such as Micro.blog).
+func (s *WPService) replaceCategory(client *micropub.Client, old, new string) error {
+	items, err := client.GetPosts(nil)
+	if err != nil {
+		return err
+	}
+
+	reported := false
+	replaced := 0
+
+	for _, item := range items {
+		props := &item.Properties
+		if _, ok := props.Raw["category"]; ok {
+			reported = true
+		}
+		if !contains(props.Category, old) || len(props.URL) == 0 {
+			continue
+		}
+
+		update := &micropub.Update{Delete: micropub.Properties{"category": {old}}}
+		if new != "" && !contains(props.Category, new) {
+			update.Add = micropub.Properties{"category": {new}}
+		}
+
+		if _, err := client.Update(props.URL[0], update); err != nil {
+			return err
+		}
+		replaced++
+
+		log.WithFields(log.Fields{"url": props.URL[0], "old": old, "new": new}).Info("replaced category")
+	}
+
+	if replaced == 0 {
+		if !reported && len(items) > 0 {
+			return ErrCategoriesNotReported
+		}
+		return ErrCategoryUnused
+	}
+
+	return nil
+}
+
+// isPending reports whether the category with the given ID is pending in the
+// client's catalog, i.e. only known to the bridge.
+func (s *WPService) isPending(client *micropub.Client, id string) (bool, error) {
+	// Listing the categories clears those the server has learned about.
+	if _, err := s.categories(client); err != nil {
+		return false, err
+	}
+	return contains(s.ids.Pending(catalog(client)), id), nil
+}
+
+// renameCategory renames the category with the given ID, on posts as well
+// as in the registry, so that its ID stays the same. The registry is only
+// updated once the posts have been rewritten, unless the category is
+// pending and so not used on any post.
+func (s *WPService) renameCategory(client *micropub.Client, id, name string) error {
+	old, ok := s.ids.Key(kindCategory, id)
+	if !ok {
+		return xmlrpc.ErrNotFound
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return &xmlrpc.FaultError{StatusCode: http.StatusForbidden, Text: "the category name cannot be empty"}
+	}
+	if name == old {
+		return nil
+	}
+
+	pending, err := s.isPending(client, id)
+	if err != nil {
+		return err
+	}
+	if !pending {
+		if err := s.replaceCategory(client, old, name); err != nil {
+			return err
+		}
+	}
+
+	return s.ids.Rename(kindCategory, id, name)
+}
+
+// deleteCategory removes the category with the given ID from every post that
+// has it, or from the catalog if it's pending.
+func (s *WPService) deleteCategory(client *micropub.Client, id string) error {
+	name, ok := s.ids.Key(kindCategory, id)
+	if !ok {
+		return xmlrpc.ErrNotFound
+	}
+
+	pending, err := s.isPending(client, id)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return s.ids.SetPending(catalog(client), id, false)
+	}
+
+	return s.replaceCategory(client, name, "")
+}
+
+// termSplitter tells tags apart from categories among the Micropub category
+// values of posts, which Micropub doesn't distinguish.
+type termSplitter struct {
+	// prefix marks tag values. Without one, there are no tags.
+	prefix string
+}
+
+// termSplitter returns the splitter for the values of posts.
+func (s *WPService) termSplitter() *termSplitter {
+	return &termSplitter{prefix: s.config.TagPrefix}
+}
+
+// split returns the name of the term a Micropub category value stands for,
+// and whether the term is a tag.
+func (t *termSplitter) split(value string) (string, bool) {
+	if t.prefix != "" && strings.HasPrefix(value, t.prefix) {
+		return strings.TrimPrefix(value, t.prefix), true
+	}
+	return value, false
+}
+
+// tagValue returns the Micropub category value for the named tag.
+func (s *WPService) tagValue(name string) string {
+	return s.config.TagPrefix + name
+}
+
+// listedTags returns the names of the tags the Micropub server lists among
+// its categories, which it only does for tags marked with a prefix.
+func (s *WPService) listedTags(client *micropub.Client) ([]string, error) {
+	if s.config.TagPrefix == "" {
+		return []string{}, nil
+	}
+
+	values, err := client.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, v := range values {
+		if strings.HasPrefix(v, s.config.TagPrefix) {
+			names = append(names, strings.TrimPrefix(v, s.config.TagPrefix))
+		}
+	}
+
+	return names, nil
+}
+
+// term returns the term of a taxonomy with the given name.
+func (s *WPService) term(taxonomy, name string, count int) (Term, error) {
+	kind := kindCategory
+	if taxonomy == taxonomyPostTag {
+		kind = kindTag
+	}
+
+	id, err := s.ids.ID(kind, name)
+	if err != nil {
+		return Term{}, err
+	}
+	return Term{
+		ID:             id,
+		Name:           name,
+		Slug:           slugify(name),
+		TermGroup:      "0",
+		TermTaxonomyID: id,
+		Taxonomy:       taxonomy,
+		Parent:         "0",
+		Count:          count,
+	}, nil
+}
+
+// itemTerms returns the category and tag terms of a Micropub item.
+func (s *WPService) itemTerms(item *micropub.Item, split *termSplitter) ([]Term, error) {
+	terms := []Term{}
+	for _, c := range item.Properties.Category {
+		taxonomy := taxonomyCategory
+		name, tag := split.split(c)
+		if tag {
+			taxonomy = taxonomyPostTag
+		}
+
+		term, err := s.term(taxonomy, name, 0)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// terms returns the terms of a taxonomy: those the Micropub server lists,
+// plus any it doesn't that are used on posts. Counting them takes the full
+// list of posts.
+func (s *WPService) terms(client *micropub.Client, taxonomy string) ([]Term, error) {
+	split := s.termSplitter()
+
+	var names []string
+	var err error
+	if taxonomy == taxonomyPostTag {
+		names, err = s.listedTags(client)
+	} else {
+		names, err = s.categories(client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := client.GetPosts(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, item := range items {
+		for _, c := range item.Properties.Category {
+			name, tag := split.split(c)
+			if tag != (taxonomy == taxonomyPostTag) {
+				continue
+			}
+			if _, ok := counts[name]; !ok && !contains(names, name) {
+				names = append(names, name)
+			}
+			counts[name]++
+		}
+	}
+
+	terms := []Term{}
+	err = s.ids.Batch(func() error {
+		for _, name := range names {
+			term, err := s.term(taxonomy, name, counts[name])
+			if err != nil {
+				return err
+			}
+			terms = append(terms, term)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
+
+// filterTerms applies the search, hide_empty, ordering and paging of a
+// wp.getTerms filter to terms.
+func filterTerms(terms []Term, filter *TermFilter) []Term {
+	filtered := []Term{}
+	search := strings.ToLower(filter.Search)
+	for _, term := range terms {
+		if filter.HideEmpty && term.Count == 0 {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(term.Name), search) {
+			continue
+		}
+		filtered = append(filtered, term)
+	}
+
+	var less func(a, b *Term) bool
+	switch filter.OrderBy {
+	case "count":
+		less = func(a, b *Term) bool { return a.Count < b.Count }
+	case "slug":
+		less = func(a, b *Term) bool { return a.Slug < b.Slug }
+	case "id", "term_id":
+		less = func(a, b *Term) bool { return postIDLess(a.ID, b.ID) }
+	default:
+		less = func(a, b *Term) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	}
+	desc := strings.EqualFold(filter.Order, "desc")
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if desc {
+			return less(&filtered[j], &filtered[i])
+		}
+		return less(&filtered[i], &filtered[j])
+	})
+
+	if filter.Offset >= len(filtered) {
+		return []Term{}
+	}
+	filtered = filtered[filter.Offset:]
+
+	if filter.Number > 0 && filter.Number < len(filtered) {
+		filtered = filtered[:filter.Number]
+	}
+
+	return filtered
+}
+
+type GetTaxonomiesArgs struct {
+	BlogID   string
+	Username string
+	Password string
+}
+
+type GetTaxonomiesReply struct {
+	Taxonomies []Taxonomy
+}
+
+func (s *WPService) GetTaxonomies(req *http.Request, args *GetTaxonomiesArgs, reply *GetTaxonomiesReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+	}).Info("---> wp.GetTaxonomies")
+
+	if _, err := s.checkAuth(args.BlogID, args.Username, args.Password); err != nil {
+		return err
+	}
+
+	reply.Taxonomies = taxonomies
+
+	return nil
+}
+
+type GetTaxonomyArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Taxonomy string
+}
+
+type GetTaxonomyReply struct {
+	Taxonomy Taxonomy
+}
+
+func (s *WPService) GetTaxonomy(req *http.Request, args *GetTaxonomyArgs, reply *GetTaxonomyReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"tax": args.Taxonomy,
+	}).Info("---> wp.GetTaxonomy")
+
+	if _, err := s.checkAuth(args.BlogID, args.Username, args.Password); err != nil {
+		return err
+	}
+
+	taxonomy, ok := findTaxonomy(args.Taxonomy)
+	if !ok {
+		return ErrInvalidTaxonomy
+	}
+
+	reply.Taxonomy = *taxonomy
+
+	return nil
+}
+
+type GetTermsArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Taxonomy string
+	Filter   TermFilter
+}
+
+type GetTermsReply struct {
+	Terms []Term
+}
+
+func (s *WPService) GetTerms(req *http.Request, args *GetTermsArgs, reply *GetTermsReply) error {
+	log.WithFields(log.Fields{
+		"bid":    args.BlogID,
+		"u":      args.Username,
+		"tax":    args.Taxonomy,
+		"filter": args.Filter,
+	}).Info("---> wp.GetTerms")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := findTaxonomy(args.Taxonomy); !ok {
+		return ErrInvalidTaxonomy
+	}
+
+	terms, err := s.terms(client, args.Taxonomy)
+	if err != nil {
+		return err
+	}
+
+	reply.Terms = filterTerms(terms, &args.Filter)
+
+	return nil
+}
+
+type GetTermArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Taxonomy string
+	TermID   string
+}
+
+type GetTermReply struct {
+	Term Term
+}
+
+func (s *WPService) GetTerm(req *http.Request, args *GetTermArgs, reply *GetTermReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"tax": args.Taxonomy,
+		"tid": args.TermID,
+	}).Info("---> wp.GetTerm")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := findTaxonomy(args.Taxonomy); !ok {
+		return ErrInvalidTaxonomy
+	}
+
+	terms, err := s.terms(client, args.Taxonomy)
+	if err != nil {
+		return err
+	}
+
+	for _, term := range terms {
+		if term.ID == args.TermID {
+			reply.Term = term
+			return nil
+		}
+	}
+
+	return xmlrpc.ErrNotFound
+}
+
+type NewTermArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Content  TermContent
+}
+
+type NewTermReply struct {
+	TermID string
+}
+
+// NewTerm creates a category. Micropub has no way to create a category by
+// itself, so it's pending until it's used on a post.
+func (s *WPService) NewTerm(req *http.Request, args *NewTermArgs, reply *NewTermReply) error {
+	log.WithFields(log.Fields{
+		"bid":  args.BlogID,
+		"u":    args.Username,
+		"tax":  args.Content.Taxonomy,
+		"name": args.Content.Name,
+	}).Info("---> wp.NewTerm")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeCreate)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := findTaxonomy(args.Content.Taxonomy); !ok {
+		return ErrInvalidTaxonomy
+	}
+	if args.Content.Taxonomy != taxonomyCategory {
+		return &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "only categories can be created"}
+	}
+
+	id, err := s.newCategory(client, args.Content.Name)
+	if err != nil {
+		return err
+	}
+
+	reply.TermID = id
+
+	return nil
+}
+
+type EditTermArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	TermID   string
+	Content  TermContent
+}
+
+type EditTermReply struct {
+	Success bool
+}
+
+// EditTerm renames a category, rewriting the posts that use it.
+func (s *WPService) EditTerm(req *http.Request, args *EditTermArgs, reply *EditTermReply) error {
+	log.WithFields(log.Fields{
+		"bid":  args.BlogID,
+		"u":    args.Username,
+		"tid":  args.TermID,
+		"name": args.Content.Name,
+	}).Info("---> wp.EditTerm")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeUpdate)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := findTaxonomy(args.Content.Taxonomy); !ok {
+		return ErrInvalidTaxonomy
+	}
+	if args.Content.Taxonomy != taxonomyCategory {
+		return &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "only categories can be edited"}
+	}
+
+	if args.Content.Name != "" {
+		if err := s.renameCategory(client, args.TermID, args.Content.Name); err != nil {
+			return err
+		}
+	}
+
+	reply.Success = true
+
+	return nil
+}
+
+type DeleteTermArgs struct {
+	BlogID   string
+	Username string
+	Password string
+	Taxonomy string
+	TermID   string
+}
+
+type DeleteTermReply struct {
+	Success bool
+}
+
+// DeleteTerm deletes a category, removing it from the posts that use it.
+func (s *WPService) DeleteTerm(req *http.Request, args *DeleteTermArgs, reply *DeleteTermReply) error {
+	log.WithFields(log.Fields{
+		"bid": args.BlogID,
+		"u":   args.Username,
+		"tax": args.Taxonomy,
+		"tid": args.TermID,
+	}).Info("---> wp.DeleteTerm")
+
+	client, err := s.checkAuth(args.BlogID, args.Username, args.Password, scopeUpdate)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := findTaxonomy(args.Taxonomy); !ok {
+		return ErrInvalidTaxonomy
+	}
+	if args.Taxonomy != taxonomyCategory {
+		return &xmlrpc.FaultError{StatusCode: http.StatusNotImplemented, Text: "only categories can be deleted"}
+	}
+
+	if err := s.deleteCategory(client, args.TermID); err != nil {
+		return err
+	}
+
+	reply.Success = true
+
+	return nil
+}
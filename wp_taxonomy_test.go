@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestTermSplitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		value  string
+		term   string
+		tag    bool
+	}{
+		{"category", "#", "Travel", "Travel", false},
+		{"tag", "#", "#beach", "beach", true},
+		{"prefix inside", "#", "C#", "C#", false},
+		{"no prefix", "", "Travel", "Travel", false},
+		{"no prefix, prefixed value", "", "#beach", "#beach", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testService()
+			s.config.TagPrefix = test.prefix
+
+			term, tag := s.termSplitter().split(test.value)
+			if term != test.term || tag != test.tag {
+				t.Errorf("got (%q, %v), want (%q, %v)", term, tag, test.term, test.tag)
+			}
+		})
+	}
+}
@@ -23,41 +23,111 @@ type Enclosure struct {
 }
 
 type Term struct {
-	ID       string `xml:"term_id"`
-	Name     string `xml:"name"`
-	Taxonomy string `xml:"taxonomy"`
+	ID             string `xml:"term_id"`
+	Name           string `xml:"name"`
+	Slug           string `xml:"slug"`
+	TermGroup      string `xml:"term_group"`
+	TermTaxonomyID string `xml:"term_taxonomy_id"`
+	Taxonomy       string `xml:"taxonomy"`
+	Description    string `xml:"description"`
+	Parent         string `xml:"parent"`
+	Count          int    `xml:"count"`
 }
 
+// TermContent is the content struct clients send to wp.newTerm.
+type TermContent struct {
+	Name        string `xml:"name"`
+	Taxonomy    string `xml:"taxonomy"`
+	Slug        string `xml:"slug"`
+	Description string `xml:"description"`
+	Parent      string `xml:"parent"`
+}
+
+// TermFilter is the filter struct clients send to wp.getTerms.
+type TermFilter struct {
+	Number    int    `xml:"number"`
+	Offset    int    `xml:"offset"`
+	OrderBy   string `xml:"orderby"`
+	Order     string `xml:"order"`
+	HideEmpty bool   `xml:"hide_empty"`
+	Search    string `xml:"search"`
+}
+
+type Taxonomy struct {
+	Name         string            `xml:"name"`
+	Label        string            `xml:"label"`
+	Hierarchical bool              `xml:"hierarchical"`
+	Public       bool              `xml:"public"`
+	ShowUI       bool              `xml:"show_ui"`
+	Builtin      bool              `xml:"_builtin"`
+	Labels       map[string]string `xml:"labels"`
+	Cap          map[string]string `xml:"cap"`
+	ObjectType   []string          `xml:"object_type"`
+}
+
+// MediaObject is the struct returned by metaWeblog.newMediaObject and
+// wp.uploadFile.
+type MediaObject struct {
+	ID   string `xml:"id"`
+	File string `xml:"file"`
+	URL  string `xml:"url"`
+	Type string `xml:"type"`
+}
+
+// PostThumbnail is an attachment, as returned by wp.getMediaLibrary and
+// wp.getMediaItem.
 type PostThumbnail struct {
-	AttachmentID   string    `xml:"attachment_id"`
-	DateCreatedGMT time.Time `xml:"date_created_gmt"`
-	ParentID       int       `xml:"parent_id"`
-	Link           string    `xml:"link"`
-	Title          string    `xml:"title"`
-	Caption        string    `xml:"caption"`
-	Description    string    `xml:"description"`
+	AttachmentID   string        `xml:"attachment_id"`
+	DateCreatedGMT time.Time     `xml:"date_created_gmt"`
+	ParentID       int           `xml:"parent_id"`
+	Link           string        `xml:"link"`
+	Title          string        `xml:"title"`
+	Caption        string        `xml:"caption"`
+	Description    string        `xml:"description"`
+	Type           string        `xml:"type"`
+	Thumbnail      string        `xml:"thumbnail"`
+	Metadata       MediaMetadata `xml:"metadata"`
+}
+
+// MediaMetadata describes the file of an attachment. The dimensions of
+// images aren't known, so they're reported as 0.
+type MediaMetadata struct {
+	Width  int    `xml:"width"`
+	Height int    `xml:"height"`
+	File   string `xml:"file"`
+}
+
+// MediaFilter is the filter struct clients send to wp.getMediaLibrary.
+type MediaFilter struct {
+	Number   int    `xml:"number"`
+	Offset   int    `xml:"offset"`
+	ParentID int    `xml:"parent_id"`
+	MIMEType string `xml:"mime_type"`
 }
 
 type Post struct {
-	PostID        string    `xml:"post_id"`
-	Title         string    `xml:"post_title"`
-	Date          time.Time `xml:"post_date"`
-	DateModified  time.Time `xml:"post_modified"`
-	Status        string    `xml:"post_status"`
-	Type          string    `xml:"post_type"`
-	Format        string    `xml:"post_format"`
-	Password      string    `xml:"post_password"`
-	Name          string    `xml:"post_name"` // note: url-safe slug
-	Author        string    `xml:"post_author"`
-	Content       string    `xml:"post_content"`
-	Parent        string    `xml:"post_parent"`
-	MIMEType      string    `xml:"post_mime_type"`
-	Link          string    `xml:"link"`
-	GUID          string    `xml:"guid"`
-	MenuOrder     int       `xml:"menu_order"`
-	CommentStatus string    `xml:"comment_status"`
-	PingStatus    string    `xml:"ping_status"`
-	Sticky        bool      `xml:"sticky"`
+	PostID          string    `xml:"post_id"`
+	Title           string    `xml:"post_title"`
+	Date            time.Time `xml:"post_date"`
+	DateGMT         time.Time `xml:"post_date_gmt"`
+	DateModified    time.Time `xml:"post_modified"`
+	DateModifiedGMT time.Time `xml:"post_modified_gmt"`
+	Status          string    `xml:"post_status"`
+	Type            string    `xml:"post_type"`
+	Format          string    `xml:"post_format"`
+	Password        string    `xml:"post_password"`
+	Name            string    `xml:"post_name"` // note: url-safe slug
+	Author          string    `xml:"post_author"`
+	Content         string    `xml:"post_content"`
+	Excerpt         string    `xml:"post_excerpt"`
+	Parent          string    `xml:"post_parent"`
+	MIMEType        string    `xml:"post_mime_type"`
+	Link            string    `xml:"link"`
+	GUID            string    `xml:"guid"`
+	MenuOrder       int       `xml:"menu_order"`
+	CommentStatus   string    `xml:"comment_status"`
+	PingStatus      string    `xml:"ping_status"`
+	Sticky          bool      `xml:"sticky"`
 	// PostThumbnail PostThumbnail `xml:"post_thumbnail"`
 
 	Terms        []Term        `xml:"terms"`
@@ -65,9 +135,138 @@ type Post struct {
 	Enclosure    Enclosure     `xml:"enclosure"`
 }
 
+// PostContent is the content struct clients send to wp.newPost and
+// wp.editPost. Every member is optional, so members are pointers that are only
+// set when the client sent them.
+type PostContent struct {
+	Type       *string    `xml:"post_type"`
+	Status     *string    `xml:"post_status"`
+	Title      *string    `xml:"post_title"`
+	Author     *string    `xml:"post_author"`
+	Content    *string    `xml:"post_content"`
+	Excerpt    *string    `xml:"post_excerpt"`
+	Date       *time.Time `xml:"post_date"`
+	DateGMT    *time.Time `xml:"post_date_gmt,utc"`
+	Format     *string    `xml:"post_format"`
+	Name       *string    `xml:"post_name"` // note: url-safe slug
+	Terms      *PostTerms `xml:"terms"`
+	TermsNames *PostTerms `xml:"terms_names"`
+	Keywords   *string    `xml:"mt_keywords"` // comma-separated tag names
+	Enclosure  *Enclosure `xml:"enclosure"`
+}
+
+// MetaWeblog is the post struct of the MetaWeblog API, as returned by
+// metaWeblog.getPost and metaWeblog.getRecentPosts.
+type MetaWeblog struct {
+	PostID         string    `xml:"postid"`
+	Title          string    `xml:"title"`
+	Description    string    `xml:"description"`
+	DateCreated    time.Time `xml:"dateCreated"`
+	DateCreatedGMT time.Time `xml:"date_created_gmt"`
+	Categories     []string  `xml:"categories"`
+	Keywords       string    `xml:"mt_keywords"`
+	Status         string    `xml:"post_status"`
+	Excerpt        string    `xml:"mt_excerpt"`
+	TextMore       string    `xml:"mt_text_more"`
+	Slug           string    `xml:"wp_slug"`
+	Link           string    `xml:"link"`
+	PermaLink      string    `xml:"permaLink"`
+	UserID         string    `xml:"userid"`
+	Enclosure      Enclosure `xml:"enclosure"`
+}
+
+// MetaWeblogContent is the post struct clients send to metaWeblog.newPost and
+// metaWeblog.editPost. As with PostContent, members are only set when the
+// client sent them.
+type MetaWeblogContent struct {
+	Title          *string    `xml:"title"`
+	Description    *string    `xml:"description"`
+	DateCreated    *time.Time `xml:"dateCreated"`
+	DateCreatedGMT *time.Time `xml:"date_created_gmt,utc"`
+	Categories     []string   `xml:"categories"`
+	Keywords       *string    `xml:"mt_keywords"`
+	Status         *string    `xml:"post_status"`
+	Excerpt        *string    `xml:"mt_excerpt"`
+	TextMore       *string    `xml:"mt_text_more"`
+	Slug           *string    `xml:"wp_slug"`
+	Enclosure      *Enclosure `xml:"enclosure"`
+}
+
+// PostTerms maps taxonomies to term IDs (in PostContent.Terms) or term names
+// (in PostContent.TermsNames). A nil slice means the client didn't send the
+// taxonomy; an empty one clears it.
+type PostTerms struct {
+	Category []string `xml:"category"`
+	PostTag  []string `xml:"post_tag"`
+}
+
+// Page is the struct returned by the legacy page methods (wp.getPage,
+// wp.getPages).
+type Page struct {
+	DateCreated       time.Time     `xml:"dateCreated"`
+	UserID            string        `xml:"userid"`
+	PageID            string        `xml:"page_id"`
+	Status            string        `xml:"page_status"`
+	Description       string        `xml:"description"`
+	Title             string        `xml:"title"`
+	Link              string        `xml:"link"`
+	PermaLink         string        `xml:"permaLink"`
+	Categories        []string      `xml:"categories"`
+	Excerpt           string        `xml:"excerpt"`
+	TextMore          string        `xml:"text_more"`
+	AllowComments     int           `xml:"mt_allow_comments"`
+	AllowPings        int           `xml:"mt_allow_pings"`
+	Slug              string        `xml:"wp_slug"`
+	Password          string        `xml:"wp_password"`
+	Author            string        `xml:"wp_author"`
+	ParentID          int           `xml:"wp_page_parent_id"`
+	ParentTitle       string        `xml:"wp_page_parent_title"`
+	Order             int           `xml:"wp_page_order"`
+	AuthorID          string        `xml:"wp_author_id"`
+	AuthorDisplayName string        `xml:"wp_author_display_name"`
+	DateCreatedGMT    time.Time     `xml:"date_created_gmt"`
+	CustomFields      []CustomField `xml:"custom_fields"`
+	Template          string        `xml:"wp_page_template"`
+}
+
+// PageContent is the content struct clients send to wp.newPage and
+// wp.editPage. As with PostContent, every member is optional.
+type PageContent struct {
+	Title          *string    `xml:"title"`
+	Description    *string    `xml:"description"`
+	DateCreated    *time.Time `xml:"dateCreated"`
+	DateCreatedGMT *time.Time `xml:"date_created_gmt,utc"`
+	Status         *string    `xml:"page_status"`
+	Slug           *string    `xml:"wp_slug"`
+}
+
+// PostFilter is the filter argument of wp.getPosts.
+type PostFilter struct {
+	PostType   string `xml:"post_type"`
+	PostStatus string `xml:"post_status"`
+	Number     int    `xml:"number"`
+	Offset     int    `xml:"offset"`
+	OrderBy    string `xml:"orderby"`
+	Order      string `xml:"order"`
+}
+
+// Tag is a tag as listed by wp.getTags.
 type Tag struct {
-	ID   int    `xml:"tag_id"`
-	Name string `xml:"name"`
+	ID      string `xml:"tag_id"`
+	Name    string `xml:"name"`
+	Count   int    `xml:"count"`
+	Slug    string `xml:"slug"`
+	HTMLURL string `xml:"html_url"`
+	RSSURL  string `xml:"rss_url"`
+}
+
+// UserBlog is a blog as listed by wp.getUsersBlogs and blogger.getUsersBlogs.
+type UserBlog struct {
+	IsAdmin  bool   `xml:"isAdmin"`
+	URL      string `xml:"url"`
+	BlogID   string `xml:"blogid"`
+	BlogName string `xml:"blogName"`
+	XMLRPC   string `xml:"xmlrpc"`
 }
 
 type User struct {
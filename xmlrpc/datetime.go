@@ -0,0 +1,73 @@
+package xmlrpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// floating is the location of decoded dateTime.iso8601 values that carried
+// no zone. They're resolved to a real location (the codec's Location, or UTC
+// for members tagged "utc") when they're mapped onto args.
+var floating = time.FixedZone("floating", 0)
+
+// Layouts of the ISO 8601 variants clients send. The spec's own example,
+// 19980717T14:08:55, has no zone; other clients add one, use the extended
+// date format, or drop the colons altogether. Fractional seconds are always
+// accepted when parsing.
+var (
+	zonedLayouts = []string{
+		"20060102T15:04:05Z07:00",
+		"20060102T15:04:05Z0700",
+		"20060102T150405Z07:00",
+		"20060102T150405Z0700",
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04:05Z0700",
+	}
+	floatingLayouts = []string{
+		"20060102T15:04:05",
+		"20060102T150405",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"20060102",
+	}
+)
+
+// parseDateTime parses a dateTime.iso8601 value. Values without a zone are
+// returned in the floating location.
+func parseDateTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	for _, layout := range zonedLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range floatingLayouts {
+		if t, err := time.ParseInLocation(layout, s, floating); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid dateTime.iso8601 value '%s'", s)
+}
+
+// resolveTime moves a floating time into loc, keeping its wall clock. Other
+// times are returned unchanged.
+func resolveTime(t time.Time, loc *time.Location) time.Time {
+	if t.Location() != floating {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// formatDateTime formats a time as dateTime.iso8601 in its own location, the
+// way WordPress does. Times in UTC are marked with a trailing Z; others carry
+// no zone, so callers should convert them to the blog's timezone first.
+func formatDateTime(t time.Time) string {
+	s := t.Format("20060102T15:04:05")
+	if t.Location() == time.UTC {
+		s += "Z"
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package xmlrpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     time.Time
+		floating bool
+	}{
+		{"19980717T14:08:55", time.Date(1998, 7, 17, 14, 8, 55, 0, floating), true},
+		{"19980717T140855", time.Date(1998, 7, 17, 14, 8, 55, 0, floating), true},
+		{"1998-07-17T14:08:55", time.Date(1998, 7, 17, 14, 8, 55, 0, floating), true},
+		{"1998-07-17 14:08:55", time.Date(1998, 7, 17, 14, 8, 55, 0, floating), true},
+		{"19980717", time.Date(1998, 7, 17, 0, 0, 0, 0, floating), true},
+		{" 19980717T14:08:55 ", time.Date(1998, 7, 17, 14, 8, 55, 0, floating), true},
+		{"19980717T14:08:55.5", time.Date(1998, 7, 17, 14, 8, 55, 5e8, floating), true},
+		{"19980717T14:08:55Z", time.Date(1998, 7, 17, 14, 8, 55, 0, time.UTC), false},
+		{"20200101T10:00:00+02:00", time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), false},
+		{"20200101T10:00:00-0500", time.Date(2020, 1, 1, 15, 0, 0, 0, time.UTC), false},
+		{"20200101T100000+02:00", time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), false},
+		{"2020-01-01T10:00:00+02:00", time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := parseDateTime(test.in)
+			if err != nil {
+				t.Fatalf("parseDateTime: %v", err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if (got.Location() == floating) != test.floating {
+				t.Errorf("got location %v, want floating=%v", got.Location(), test.floating)
+			}
+		})
+	}
+}
+
+func TestParseDateTimeErrors(t *testing.T) {
+	for _, in := range []string{"", "yesterday", "2020-13-01T00:00:00", "20200101T25:00:00"} {
+		if got, err := parseDateTime(in); err == nil {
+			t.Errorf("parseDateTime(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestResolveTime(t *testing.T) {
+	chicago := time.FixedZone("CST", -6*60*60)
+
+	got := resolveTime(time.Date(2020, 1, 1, 10, 0, 0, 0, floating), chicago)
+	if want := time.Date(2020, 1, 1, 10, 0, 0, 0, chicago); !got.Equal(want) || got.Location() != chicago {
+		t.Errorf("floating: got %v, want %v", got, want)
+	}
+
+	zoned := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	if got := resolveTime(zoned, chicago); got != zoned {
+		t.Errorf("zoned: got %v, want %v", got, zoned)
+	}
+}
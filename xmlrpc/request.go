@@ -4,7 +4,9 @@ import (
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
-	"time"
+	"strconv"
+	"strings"
+	"unicode"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -31,7 +33,15 @@ type XMLRPCStructMember struct {
 	Value   XMLRPCValue `xml:"value"`
 }
 
+// UnmarshalXML decodes a <value> into a Go value: string, int, float64, bool,
+// time.Time, []byte (for <base64>), []XMLRPCValue (for <array>),
+// XMLRPCStruct, or nil. Untyped values are strings, per the spec. The nil,
+// i8 and Apache "ex:" extension types are accepted too; namespace prefixes
+// are ignored.
 func (v *XMLRPCValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text []byte
+	typed := false
+
 	for {
 		tok, err := d.Token()
 		if err != nil {
@@ -40,65 +50,115 @@ func (v *XMLRPCValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 		}
 
 		switch el := tok.(type) {
+		case xml.CharData:
+			if !typed {
+				text = append(text, el...)
+			}
 		case xml.StartElement:
-			switch el.Name.Local {
-			case "array":
-				var a struct {
-					Items []XMLRPCValue `xml:"data>value"`
-				}
-				if err := d.DecodeElement(&a, &el); err != nil {
-					return err
-				}
-				v.Value = a.Items
-			case "struct":
-				var s XMLRPCStruct
-				if err := d.DecodeElement(&s, &el); err != nil {
-					return err
-				}
-				v.Value = s
-			case "string":
-				var s string
-				if err := d.DecodeElement(&s, &el); err != nil {
-					return err
-				}
-				v.Value = s
-			case "int":
-				var i int
-				if err := d.DecodeElement(&i, &el); err != nil {
-					return err
-				}
-				v.Value = i
-			case "boolean":
-				var b bool
-				if err := d.DecodeElement(&b, &el); err != nil {
-					return err
-				}
-				v.Value = b
-			case "dateTime.iso8601":
-				var t time.Time
-				if err := d.DecodeElement(&t, &el); err != nil {
-					return err
-				}
-				v.Value = t
-			case "base64":
-				var s string
-				if err := d.DecodeElement(&s, &el); err != nil {
-					return err
-				}
-
-				b, err := base64.StdEncoding.DecodeString(s)
-				if err != nil {
-					return err
-				}
-				v.Value = string(b)
-			default:
-				return fmt.Errorf("unknown XMLRPCValue type '%s'", el.Name.Local)
+			typed = true
+			if err := v.decodeTyped(d, el); err != nil {
+				return err
 			}
 		case xml.EndElement:
 			if el == start.End() {
+				if !typed {
+					v.Value = string(text)
+				}
 				return nil
 			}
 		}
 	}
+}
+
+func (v *XMLRPCValue) decodeTyped(d *xml.Decoder, el xml.StartElement) error {
+	switch el.Name.Local {
+	case "array":
+		var a struct {
+			Items []XMLRPCValue `xml:"data>value"`
+		}
+		if err := d.DecodeElement(&a, &el); err != nil {
+			return err
+		}
+		v.Value = a.Items
+	case "struct":
+		var s XMLRPCStruct
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+		v.Value = s
+	case "string", "biginteger", "bigdecimal":
+		var s string
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+		v.Value = s
+	case "int", "i1", "i2", "i4", "i8":
+		var s string
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.Value = int(i)
+	case "double", "float":
+		var s string
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return err
+		}
+		v.Value = f
+	case "boolean":
+		var s string
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.Value = b
+	case "dateTime.iso8601", "dateTime":
+		var s string
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+		t, err := parseDateTime(s)
+		if err != nil {
+			return err
+		}
+		v.Value = t
+	case "base64":
+		var s string
+		if err := d.DecodeElement(&s, &el); err != nil {
+			return err
+		}
+
+		// Clients commonly wrap base64 data across lines.
+		s = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, s)
+
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		v.Value = b
+	case "nil":
+		if err := d.Skip(); err != nil {
+			return err
+		}
+		v.Value = nil
+	default:
+		return fmt.Errorf("unknown XMLRPCValue type '%s'", el.Name.Local)
+	}
+
 	return nil
 }
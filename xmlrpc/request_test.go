@@ -0,0 +1,195 @@
+package xmlrpc
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestXMLRPCValueUnmarshalXML(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want interface{}
+	}{
+		{"untyped", `<value>hello</value>`, "hello"},
+		{"empty untyped", `<value></value>`, ""},
+		{"string", `<value><string>a &amp; b</string></value>`, "a & b"},
+		{"empty string", `<value><string/></value>`, ""},
+		{"int", `<value><int>42</int></value>`, 42},
+		{"i4", `<value><i4>-7</i4></value>`, -7},
+		{"i8", `<value><i8>8589934592</i8></value>`, 8589934592},
+		{"ex:i8", `<value><ex:i8 xmlns:ex="http://ws.apache.org/xmlrpc/namespaces/extensions">9</ex:i8></value>`, 9},
+		{"int with spaces", `<value><int> 3 </int></value>`, 3},
+		{"double", `<value><double>1.5</double></value>`, 1.5},
+		{"boolean true", `<value><boolean>1</boolean></value>`, true},
+		{"boolean false", `<value><boolean>0</boolean></value>`, false},
+		{
+			"dateTime",
+			`<value><dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601></value>`,
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{"base64", `<value><base64>aGVsbG8=</base64></value>`, []byte("hello")},
+		{"wrapped base64", "<value><base64>aGVs\n  bG8=</base64></value>", []byte("hello")},
+		{"nil", `<value><nil/></value>`, nil},
+		{"ex:nil", `<value><ex:nil xmlns:ex="http://ws.apache.org/xmlrpc/namespaces/extensions"/></value>`, nil},
+		{
+			"array",
+			`<value><array><data><value><int>1</int></value><value>two</value></data></array></value>`,
+			[]XMLRPCValue{{Value: 1}, {Value: "two"}},
+		},
+		{"empty array", `<value><array><data></data></array></value>`, []XMLRPCValue(nil)},
+		{
+			"struct",
+			`<value><struct><member><name>a</name><value><int>1</int></value></member></struct></value>`,
+			XMLRPCStruct{Members: []XMLRPCStructMember{{Name: "a", Value: XMLRPCValue{Value: 1}}}},
+		},
+		{
+			"nested struct and array",
+			`<value><struct><member><name>terms</name><value><struct>` +
+				`<member><name>category</name><value><array><data><value><string>x</string></value></data></array></value></member>` +
+				`</struct></value></member></struct></value>`,
+			XMLRPCStruct{Members: []XMLRPCStructMember{{
+				Name: "terms",
+				Value: XMLRPCValue{Value: XMLRPCStruct{Members: []XMLRPCStructMember{{
+					Name:  "category",
+					Value: XMLRPCValue{Value: []XMLRPCValue{{Value: "x"}}},
+				}}}},
+			}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v XMLRPCValue
+			if err := xml.Unmarshal([]byte(test.xml), &v); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(stripXMLNames(v.Value), test.want) {
+				t.Errorf("got %#v, want %#v", v.Value, test.want)
+			}
+		})
+	}
+}
+
+func TestXMLRPCValueUnmarshalXMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{"bad int", `<value><int>x</int></value>`},
+		{"bad boolean", `<value><boolean>maybe</boolean></value>`},
+		{"bad dateTime", `<value><dateTime.iso8601>yesterday</dateTime.iso8601></value>`},
+		{"bad base64", `<value><base64>!!!</base64></value>`},
+		{"unknown type", `<value><decimal>1</decimal></value>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v XMLRPCValue
+			if err := xml.Unmarshal([]byte(test.xml), &v); err == nil {
+				t.Errorf("got %#v, want an error", v.Value)
+			}
+		})
+	}
+}
+
+// stripXMLNames clears the XMLName fields encoding/xml fills in, so that
+// decoded values can be compared with literals.
+func stripXMLNames(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []XMLRPCValue:
+		if v == nil {
+			return v
+		}
+		values := make([]XMLRPCValue, len(v))
+		for i := range v {
+			values[i] = XMLRPCValue{Value: stripXMLNames(v[i].Value)}
+		}
+		return values
+	case XMLRPCStruct:
+		members := make([]XMLRPCStructMember, len(v.Members))
+		for i, member := range v.Members {
+			members[i] = XMLRPCStructMember{Name: member.Name, Value: XMLRPCValue{Value: stripXMLNames(member.Value.Value)}}
+		}
+		return XMLRPCStruct{Members: members}
+	}
+	return value
+}
+
+type testMapArgs struct {
+	Date  time.Time
+	Post  testMapPost
+	Tags  []string
+	Count int
+}
+
+type testMapPost struct {
+	Title string    `xml:"post_title"`
+	Date  time.Time `xml:"post_date"`
+}
+
+func TestReadRequestMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{"struct for dateTime", `<param><value><struct><member><name></name><value><int>1</int></value></member></struct></value></param>`},
+		{"empty struct for dateTime", `<param><value><struct></struct></value></param>`},
+		{
+			"struct for nested dateTime",
+			`<param><value><dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601></value></param>` +
+				`<param><value><struct><member><name>post_date</name><value><struct><member><name></name><value><int>1</int></value></member></struct></value></member></struct></value></param>`,
+		},
+		{"string for dateTime", `<param><value><string>today</string></value></param>`},
+		{
+			"base64 for string array",
+			`<param><value><dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601></value></param>` +
+				`<param><value><struct></struct></value></param>` +
+				`<param><value><base64>aGVsbG8=</base64></value></param>`,
+		},
+		{
+			"array for int",
+			`<param><value><dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601></value></param>` +
+				`<param><value><struct></struct></value></param>` +
+				`<param><value><array><data></data></array></value></param>` +
+				`<param><value><array><data></data></array></value></param>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &CodecRequest{
+				body:     []byte("<methodCall><methodName>test.map</methodName><params>" + test.params + "</params></methodCall>"),
+				location: time.UTC,
+			}
+			var args testMapArgs
+			err := c.ReadRequest(&args)
+			if fault, ok := err.(*FaultError); !ok || fault.StatusCode != 400 {
+				t.Errorf("got %v, want a 400 fault", err)
+			}
+		})
+	}
+}
+
+func TestReadRequestIgnoresUnnamedMembers(t *testing.T) {
+	c := &CodecRequest{
+		body: []byte("<methodCall><methodName>test.map</methodName><params>" +
+			`<param><value><dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601></value></param>` +
+			`<param><value><struct>` +
+			`<member><name></name><value><int>1</int></value></member>` +
+			`<member><name>post_title</name><value>Hello</value></member>` +
+			`</struct></value></param>` +
+			"</params></methodCall>"),
+		location: time.UTC,
+	}
+
+	var args testMapArgs
+	if err := c.ReadRequest(&args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Post.Title != "Hello" || !args.Post.Date.IsZero() {
+		t.Errorf("got %+v", args.Post)
+	}
+}
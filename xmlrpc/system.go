@@ -0,0 +1,371 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	rpc "github.com/gorilla/rpc/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// SystemService implements the system.* methods clients use to batch and
+// probe calls. Services should be registered through it rather than directly
+// with the rpc server, so that they can be introspected; that includes the
+// SystemService itself, under the name "system".
+type SystemService struct {
+	server  *rpc.Server
+	methods map[string]*methodInfo // keyed by the name clients call
+}
+
+type methodInfo struct {
+	service   string
+	method    reflect.Method
+	argsType  reflect.Type
+	replyType reflect.Type
+	rcvr      interface{}
+}
+
+// Helper can be implemented by services to describe their methods to
+// system.methodHelp. The method name is the Go method name (e.g. "GetPosts").
+type Helper interface {
+	Help(method string) string
+}
+
+// NewSystemService returns a SystemService that dispatches inner calls
+// through server.
+func NewSystemService(server *rpc.Server) *SystemService {
+	return &SystemService{server: server, methods: map[string]*methodInfo{}}
+}
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfRequest = reflect.TypeOf((*http.Request)(nil))
+)
+
+// RegisterService registers rcvr with the rpc server under name and records
+// its methods for introspection. Methods are picked out using the same rules
+// the rpc server uses.
+func (s *SystemService) RegisterService(rcvr interface{}, name string) error {
+	if err := s.server.RegisterService(rcvr, name); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(rcvr)
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		mtype := method.Type
+		if method.PkgPath != "" || mtype.NumIn() != 4 || mtype.NumOut() != 1 {
+			continue
+		}
+		if mtype.In(1) != typeOfRequest || mtype.In(2).Kind() != reflect.Ptr || mtype.In(3).Kind() != reflect.Ptr {
+			continue
+		}
+		if mtype.Out(0) != typeOfError {
+			continue
+		}
+
+		s.methods[name+"."+lowerFirst(method.Name)] = &methodInfo{
+			service:   name,
+			method:    method,
+			argsType:  mtype.In(2).Elem(),
+			replyType: mtype.In(3).Elem(),
+			rcvr:      rcvr,
+		}
+	}
+
+	return nil
+}
+
+// lowerFirst undoes Codec.AutoCapitalizeMethodName, turning a Go method name
+// into the name clients call.
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+type ListMethodsArgs struct {
+}
+
+type ListMethodsReply struct {
+	Methods []string
+}
+
+// ListMethods implements system.listMethods.
+func (s *SystemService) ListMethods(req *http.Request, args *ListMethodsArgs, reply *ListMethodsReply) error {
+	log.Info("---> system.ListMethods")
+
+	reply.Methods = []string{}
+	for name := range s.methods {
+		reply.Methods = append(reply.Methods, name)
+	}
+	sort.Strings(reply.Methods)
+
+	return nil
+}
+
+type MethodSignatureArgs struct {
+	MethodName string
+}
+
+type MethodSignatureReply struct {
+	Signatures [][]string
+}
+
+// MethodSignature implements system.methodSignature. Signatures are derived
+// from the method's args and reply structs: the first element is the type of
+// the reply's first field, followed by the type of each argument.
+func (s *SystemService) MethodSignature(req *http.Request, args *MethodSignatureArgs, reply *MethodSignatureReply) error {
+	log.WithField("method", args.MethodName).Info("---> system.MethodSignature")
+
+	info, ok := s.methods[args.MethodName]
+	if !ok {
+		return ErrNotFound
+	}
+
+	signature := []string{"undef"}
+	if info.replyType.NumField() > 0 {
+		signature[0] = typeName(info.replyType.Field(0).Type)
+	}
+	for i := 0; i < info.argsType.NumField(); i++ {
+		signature = append(signature, typeName(info.argsType.Field(i).Type))
+	}
+
+	reply.Signatures = [][]string{signature}
+
+	return nil
+}
+
+type MethodHelpArgs struct {
+	MethodName string
+}
+
+type MethodHelpReply struct {
+	Help string
+}
+
+// MethodHelp implements system.methodHelp.
+func (s *SystemService) MethodHelp(req *http.Request, args *MethodHelpArgs, reply *MethodHelpReply) error {
+	log.WithField("method", args.MethodName).Info("---> system.MethodHelp")
+
+	info, ok := s.methods[args.MethodName]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if helper, ok := info.rcvr.(Helper); ok {
+		reply.Help = helper.Help(info.method.Name)
+	}
+
+	return nil
+}
+
+// Help implements Helper.
+func (s *SystemService) Help(method string) string {
+	switch method {
+	case "Multicall":
+		return "Calls several methods in one request. Takes an array of {methodName, params} structs and returns an array holding each call's result (as a one-element array) or fault struct."
+	case "ListMethods":
+		return "Returns the names of every method the server supports."
+	case "MethodSignature":
+		return "Returns the possible signatures of a method as arrays of XML-RPC type names, the return type first."
+	case "MethodHelp":
+		return "Returns a description of a method."
+	}
+	return ""
+}
+
+// typeName returns the XML-RPC type name that values of t are marshalled as.
+func typeName(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return "dateTime.iso8601"
+	case reflect.TypeOf(Struct{}), reflect.TypeOf(XMLRPCStruct{}):
+		return "struct"
+	case reflect.TypeOf(XMLRPCValue{}), reflect.TypeOf(rawXML("")):
+		return "undef"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "base64"
+		}
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "struct"
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	default:
+		return "undef"
+	}
+}
+
+type MulticallArgs struct {
+	Calls []MulticallCall
+}
+
+type MulticallCall struct {
+	MethodName string        `xml:"methodName"`
+	Params     []XMLRPCValue `xml:"params"`
+}
+
+type MulticallReply struct {
+	Results []rawXML
+}
+
+// Multicall implements system.multicall. Each call is dispatched through the
+// rpc server as if it had been made on its own; its result is returned as a
+// single-element array, or its fault as a fault struct.
+func (s *SystemService) Multicall(req *http.Request, args *MulticallArgs, reply *MulticallReply) error {
+	log.WithField("n", len(args.Calls)).Info("---> system.Multicall")
+
+	reply.Results = []rawXML{}
+
+	for _, call := range args.Calls {
+		log.WithField("method", call.MethodName).Info("xmlrpc: multicall")
+
+		var result rawXML
+		if call.MethodName == "system.multicall" {
+			result = faultXML(http.StatusBadRequest, "recursive system.multicall is not allowed")
+		} else {
+			var err error
+			if result, err = s.dispatch(req, &call); err != nil {
+				return err
+			}
+		}
+
+		reply.Results = append(reply.Results, result)
+	}
+
+	return nil
+}
+
+// dispatch makes a single call of a multicall through the rpc server.
+func (s *SystemService) dispatch(req *http.Request, call *MulticallCall) (rawXML, error) {
+	params := ""
+	for i := range call.Params {
+		value := reflect.ValueOf(XMLRPCValue{Value: zonedToUTC(call.Params[i].Value)})
+		paramXML, err := marshalReplyParam(&value)
+		if err != nil {
+			return "", err
+		}
+		params += "<param><value>" + paramXML + "</value></param>"
+	}
+
+	var name bytes.Buffer
+	xml.EscapeText(&name, []byte(call.MethodName))
+
+	body := fmt.Sprintf(
+		"<methodCall><methodName>%s</methodName><params>%s</params></methodCall>",
+		name.String(),
+		params,
+	)
+
+	inner, err := http.NewRequest(http.MethodPost, req.URL.String(), strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	inner = inner.WithContext(req.Context())
+	inner.Header = req.Header.Clone()
+	inner.RemoteAddr = req.RemoteAddr
+
+	w := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	s.server.ServeHTTP(w, inner)
+
+	var resp struct {
+		Params []struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"params>param>value"`
+		Fault *struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"fault>value"`
+	}
+	if err := xml.Unmarshal(w.body.Bytes(), &resp); err != nil {
+		// Errors that aren't faults are written as plain text.
+		return faultXML(w.status, strings.TrimSpace(w.body.String())), nil
+	}
+
+	if resp.Fault != nil {
+		return rawXML(resp.Fault.Inner), nil
+	}
+
+	values := ""
+	for _, param := range resp.Params {
+		values += "<value>" + param.Inner + "</value>"
+	}
+	return rawXML("<array><data>" + values + "</data></array>"), nil
+}
+
+// zonedToUTC returns a decoded value with every zoned time in it converted to
+// UTC, which formatDateTime marks with a Z; formatDateTime drops any other
+// offset. Floating times are left as they are, to be resolved by the inner
+// call.
+func zonedToUTC(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		if v.Location() != floating {
+			return v.UTC()
+		}
+	case []XMLRPCValue:
+		values := make([]XMLRPCValue, len(v))
+		for i := range v {
+			values[i] = XMLRPCValue{Value: zonedToUTC(v[i].Value)}
+		}
+		return values
+	case XMLRPCStruct:
+		members := make([]XMLRPCStructMember, len(v.Members))
+		for i, member := range v.Members {
+			members[i] = XMLRPCStructMember{Name: member.Name, Value: XMLRPCValue{Value: zonedToUTC(member.Value.Value)}}
+		}
+		return XMLRPCStruct{Members: members}
+	}
+	return value
+}
+
+func faultXML(code int, text string) rawXML {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+
+	return rawXML(fmt.Sprintf(
+		"<struct><member><name>faultCode</name><value><int>%d</int></value></member>"+
+			"<member><name>faultString</name><value><string>%s</string></value></member></struct>",
+		code,
+		buf.String(),
+	))
+}
+
+// bufferedResponse captures the response to an inner call.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponse) WriteHeader(statusCode int) {
+	w.status = statusCode
+}
@@ -0,0 +1,314 @@
+package xmlrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	rpc "github.com/gorilla/rpc/v2"
+)
+
+type testService struct{}
+
+type EchoArgs struct {
+	Value string
+}
+
+type EchoReply struct {
+	Value string
+}
+
+func (s *testService) Echo(req *http.Request, args *EchoArgs, reply *EchoReply) error {
+	reply.Value = args.Value
+	return nil
+}
+
+type TimeArgs struct {
+	Time time.Time
+}
+
+type TimeReply struct {
+	Time time.Time
+}
+
+func (s *testService) Time(req *http.Request, args *TimeArgs, reply *TimeReply) error {
+	reply.Time = args.Time.UTC()
+	return nil
+}
+
+type FailArgs struct {
+}
+
+type FailReply struct {
+}
+
+func (s *testService) Fail(req *http.Request, args *FailArgs, reply *FailReply) error {
+	return &FaultError{StatusCode: http.StatusForbidden, Text: "nope"}
+}
+
+func newTestServer(t *testing.T) *rpc.Server {
+	server := rpc.NewServer()
+	codec := NewCodec()
+	codec.AutoCapitalizeMethodName = true
+	codec.Location = time.FixedZone("CST", -6*60*60)
+	server.RegisterCodec(codec, "text/xml")
+
+	system := NewSystemService(server)
+	if err := system.RegisterService(&testService{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.RegisterService(system, "system"); err != nil {
+		t.Fatal(err)
+	}
+
+	return server
+}
+
+func multicallBody(calls ...string) string {
+	return "<methodCall><methodName>system.multicall</methodName><params><param><value><array><data>" +
+		strings.Join(calls, "") +
+		"</data></array></value></param></params></methodCall>"
+}
+
+func multicallCall(method, params string) string {
+	return "<value><struct>" +
+		"<member><name>methodName</name><value><string>" + method + "</string></value></member>" +
+		"<member><name>params</name><value><array><data>" + params + "</data></array></value></member>" +
+		"</struct></value>"
+}
+
+// betweenTags matches the indentation faults are written with.
+var betweenTags = regexp.MustCompile(`>\s+<`)
+
+func serveMulticall(server *rpc.Server, calls ...string) string {
+	req := httptest.NewRequest(http.MethodPost, "/xmlrpc.php", strings.NewReader(multicallBody(calls...)))
+	req.Header.Set("Content-Type", "text/xml")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return betweenTags.ReplaceAllString(w.Body.String(), "><")
+}
+
+func TestMulticall(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls []string
+		want  string
+	}{
+		{
+			"single call",
+			[]string{multicallCall("test.echo", "<value><string>hi</string></value>")},
+			"<value><array><data><value><string>hi</string></value></data></array></value>",
+		},
+		{
+			"several calls",
+			[]string{
+				multicallCall("test.echo", "<value><string>a</string></value>"),
+				multicallCall("test.echo", "<value><string>b</string></value>"),
+			},
+			"<value><array><data><value><string>a</string></value></data></array></value>" +
+				"<value><array><data><value><string>b</string></value></data></array></value>",
+		},
+		{
+			"fault",
+			[]string{multicallCall("test.fail", "")},
+			"<value><struct><member><name>faultCode</name><value><int>403</int></value></member>" +
+				"<member><name>faultString</name><value><string>nope</string></value></member></struct></value>",
+		},
+		{
+			"recursive multicall",
+			[]string{multicallCall("system.multicall", "")},
+			"<value><struct><member><name>faultCode</name><value><int>400</int></value></member>" +
+				"<member><name>faultString</name><value><string>recursive system.multicall is not allowed</string></value></member></struct></value>",
+		},
+		{
+			"zoned time",
+			[]string{multicallCall("test.time", "<value><dateTime.iso8601>20200101T10:00:00+02:00</dateTime.iso8601></value>")},
+			"<value><array><data><value><dateTime.iso8601>20200101T08:00:00Z</dateTime.iso8601></value></data></array></value>",
+		},
+		{
+			"floating time",
+			[]string{multicallCall("test.time", "<value><dateTime.iso8601>20200101T10:00:00</dateTime.iso8601></value>")},
+			"<value><array><data><value><dateTime.iso8601>20200101T16:00:00Z</dateTime.iso8601></value></data></array></value>",
+		},
+	}
+
+	server := newTestServer(t)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := "<array><data>" + test.want + "</data></array>"
+			if body := serveMulticall(server, test.calls...); !strings.Contains(body, want) {
+				t.Errorf("got  %s\nwant %s", body, want)
+			}
+		})
+	}
+}
+
+func TestMulticallUnknownMethod(t *testing.T) {
+	server := newTestServer(t)
+
+	body := serveMulticall(server,
+		multicallCall("test.missing", ""),
+		multicallCall("test.echo", "<value><string>still here</string></value>"),
+	)
+	if !strings.Contains(body, "<name>faultCode</name>") {
+		t.Errorf("expected a fault for the unknown method, got %s", body)
+	}
+	if !strings.Contains(body, "<string>still here</string>") {
+		t.Errorf("expected the second call to run, got %s", body)
+	}
+}
+
+// blogService is a small service for the introspection methods.
+type blogService struct{}
+
+type GetPostArgs struct {
+	BlogID string
+	PostID int
+	Since  time.Time
+	Fields []string
+	Raw    bool
+}
+
+type GetPostReply struct {
+	Post struct {
+		Title string
+	}
+}
+
+func (s *blogService) GetPost(req *http.Request, args *GetPostArgs, reply *GetPostReply) error {
+	return nil
+}
+
+type CountPostsArgs struct {
+	BlogID string
+}
+
+type CountPostsReply struct {
+	Count int
+}
+
+func (s *blogService) CountPosts(req *http.Request, args *CountPostsArgs, reply *CountPostsReply) error {
+	return nil
+}
+
+type PingArgs struct {
+}
+
+type PingReply struct {
+}
+
+func (s *blogService) Ping(req *http.Request, args *PingArgs, reply *PingReply) error {
+	return nil
+}
+
+// unexported methods and methods of other shapes aren't callable.
+func (s *blogService) reset(req *http.Request, args *PingArgs, reply *PingReply) error {
+	return nil
+}
+
+func (s *blogService) Close() error {
+	return nil
+}
+
+func (s *blogService) Help(method string) string {
+	if method == "GetPost" {
+		return "Returns a post."
+	}
+	return ""
+}
+
+func newIntrospectionService(t *testing.T) *SystemService {
+	system := NewSystemService(rpc.NewServer())
+	if err := system.RegisterService(&blogService{}, "blog"); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.RegisterService(system, "system"); err != nil {
+		t.Fatal(err)
+	}
+	return system
+}
+
+func TestListMethods(t *testing.T) {
+	system := newIntrospectionService(t)
+
+	reply := &ListMethodsReply{}
+	if err := system.ListMethods(nil, &ListMethodsArgs{}, reply); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"blog.countPosts",
+		"blog.getPost",
+		"blog.ping",
+		"system.listMethods",
+		"system.methodHelp",
+		"system.methodSignature",
+		"system.multicall",
+	}
+	if !reflect.DeepEqual(reply.Methods, want) {
+		t.Errorf("got %v, want %v", reply.Methods, want)
+	}
+}
+
+func TestMethodSignature(t *testing.T) {
+	tests := []struct {
+		method string
+		want   [][]string
+		err    error
+	}{
+		{"blog.getPost", [][]string{{"struct", "string", "int", "dateTime.iso8601", "array", "boolean"}}, nil},
+		{"blog.countPosts", [][]string{{"int", "string"}}, nil},
+		{"blog.ping", [][]string{{"undef"}}, nil},
+		{"system.methodHelp", [][]string{{"string", "string"}}, nil},
+		{"blog.missing", nil, ErrNotFound},
+		{"blog.GetPost", nil, ErrNotFound},
+	}
+
+	system := newIntrospectionService(t)
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			reply := &MethodSignatureReply{}
+			err := system.MethodSignature(nil, &MethodSignatureArgs{MethodName: test.method}, reply)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(reply.Signatures, test.want) {
+				t.Errorf("got %v, want %v", reply.Signatures, test.want)
+			}
+		})
+	}
+}
+
+func TestMethodHelp(t *testing.T) {
+	tests := []struct {
+		method string
+		want   string
+		err    error
+	}{
+		{"blog.getPost", "Returns a post.", nil},
+		{"blog.ping", "", nil},
+		{"system.methodHelp", "Returns a description of a method.", nil},
+		{"blog.missing", "", ErrNotFound},
+	}
+
+	system := newIntrospectionService(t)
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			reply := &MethodHelpReply{}
+			err := system.MethodHelp(nil, &MethodHelpArgs{MethodName: test.method}, reply)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if reply.Help != test.want {
+				t.Errorf("got %q, want %q", reply.Help, test.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,15 @@ package xmlrpc
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +28,9 @@ func (e *FaultError) Error() string {
 }
 
 func (e *FaultError) XML() string {
+	var text bytes.Buffer
+	xml.EscapeText(&text, []byte(e.Text))
+
 	return fmt.Sprintf(`<methodResponse>
     <fault>
         <value>
@@ -39,7 +46,7 @@ func (e *FaultError) XML() string {
             </struct>
         </value>
     </fault>
-</methodResponse>`, e.StatusCode, e.Text)
+</methodResponse>`, e.StatusCode, text.String())
 }
 
 var (
@@ -50,10 +57,18 @@ var (
 
 type Codec struct {
 	AutoCapitalizeMethodName bool
+
+	// MapError, if set, is given the chance to translate errors returned by
+	// service methods (e.g. into a *FaultError) before they're written.
+	MapError func(error) error
+
+	// Location is the timezone of incoming dateTime.iso8601 values that
+	// don't specify one.
+	Location *time.Location
 }
 
 func NewCodec() *Codec {
-	return &Codec{}
+	return &Codec{Location: time.Local}
 }
 
 func (c *Codec) NewRequest(req *http.Request) rpc.CodecRequest {
@@ -77,6 +92,8 @@ func (c *Codec) NewRequest(req *http.Request) rpc.CodecRequest {
 		body:                     data,
 		method:                   methodCall.MethodName,
 		autoCapitalizeMethodName: c.AutoCapitalizeMethodName,
+		mapError:                 c.MapError,
+		location:                 c.Location,
 	}
 }
 
@@ -85,6 +102,8 @@ type CodecRequest struct {
 	body                     []byte
 	method                   string
 	autoCapitalizeMethodName bool
+	mapError                 func(error) error
+	location                 *time.Location
 }
 
 func (c *CodecRequest) Method() (string, error) {
@@ -104,15 +123,17 @@ func (c *CodecRequest) ReadRequest(args interface{}) error {
 		return err
 	}
 
+	// Trailing arguments are optional (e.g. the fields argument of
+	// wp.getPost) and are left at their zero values when omitted.
 	numArgs := reflect.TypeOf(args).Elem().NumField()
-	if numArgs != len(mc.Params) {
-		log.Errorf("xmlrpc: wrong number of arguments (expected %d, got %d)", numArgs, len(mc.Params))
+	if len(mc.Params) > numArgs {
+		log.Errorf("xmlrpc: wrong number of arguments (expected at most %d, got %d)", numArgs, len(mc.Params))
 		return fmt.Errorf("wrong number of arguments")
 	}
 
 	for i, param := range mc.Params {
 		field := reflect.ValueOf(args).Elem().Field(i)
-		if err := mapValueToField(param.Value, &field); err != nil {
+		if err := mapValueToField(param.Value, &field, c.location); err != nil {
 			log.WithError(err).Error("error mapping incoming param to field")
 			return err
 		}
@@ -134,14 +155,18 @@ func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
 }
 
 func (c *CodecRequest) WriteError(w http.ResponseWriter, status int, err error) {
+	if c.mapError != nil {
+		err = c.mapError(err)
+	}
+
 	if fault, ok := err.(*FaultError); ok {
 		status = fault.StatusCode
 
 		log.WithError(fault).Errorf("xmlrpc fault")
 
-		w.WriteHeader(status)
 		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
-		fmt.Fprintf(w, fault.XML())
+		w.WriteHeader(status)
+		fmt.Fprint(w, fault.XML())
 
 		return
 	}
@@ -181,6 +206,27 @@ func marshalReplyParams(value *reflect.Value) (string, error) {
 }
 
 func marshalReplyParam(value *reflect.Value) (string, error) {
+	switch value.Type() {
+	case reflect.TypeOf(Struct{}):
+		return marshalStruct(value.Interface().(Struct))
+	case reflect.TypeOf(rawXML("")):
+		return value.String(), nil
+	case reflect.TypeOf(XMLRPCValue{}):
+		// Values decoded from a request, e.g. the params of a
+		// system.multicall call, marshal back to what they were decoded from.
+		if value.Interface().(XMLRPCValue).Value == nil {
+			return "<nil/>", nil
+		}
+		inner := reflect.ValueOf(value.Interface().(XMLRPCValue).Value)
+		return marshalReplyParam(&inner)
+	case reflect.TypeOf(XMLRPCStruct{}):
+		s := Struct{}
+		for _, member := range value.Interface().(XMLRPCStruct).Members {
+			s = append(s, Member{Name: member.Name, Value: member.Value})
+		}
+		return marshalStruct(s)
+	}
+
 	switch value.Kind() {
 	case reflect.String:
 		var buf bytes.Buffer
@@ -193,16 +239,32 @@ func marshalReplyParam(value *reflect.Value) (string, error) {
 		reflect.Int16,
 		reflect.Int32,
 		reflect.Int64:
-		return fmt.Sprintf("<int>%d</int>", value.Int()), nil
+		return marshalInt(value.Int()), nil
 	case reflect.Uint,
 		reflect.Uint8,
 		reflect.Uint16,
 		reflect.Uint32,
 		reflect.Uint64:
-		return fmt.Sprintf("<int>%d</int>", value.Uint()), nil
+		if value.Uint() > math.MaxInt64 {
+			return "", fmt.Errorf("reply value %d overflows i8", value.Uint())
+		}
+		return marshalInt(int64(value.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("<double>%s</double>", strconv.FormatFloat(value.Float(), 'f', -1, 64)), nil
 	case reflect.Bool:
-		return fmt.Sprintf("<boolean>%t</boolean>", value.Bool()), nil
+		if value.Bool() {
+			return "<boolean>1</boolean>", nil
+		}
+		return "<boolean>0</boolean>", nil
+	case reflect.Map:
+		return marshalMap(value)
 	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(data), *value)
+			return fmt.Sprintf("<base64>%s</base64>", base64.StdEncoding.EncodeToString(data)), nil
+		}
+
 		buf := ""
 		length := value.Len()
 		for i := 0; i < length; i++ {
@@ -216,13 +278,8 @@ func marshalReplyParam(value *reflect.Value) (string, error) {
 		}
 		return fmt.Sprintf("<array><data>%s</data></array>", buf), nil
 	case reflect.Struct:
-		if reflect.TypeOf(value.Interface()).String() == "time.Time" {
-			t := value.Interface().(time.Time)
-			return fmt.Sprintf(
-				"<dateTime.iso8601>%04d%02d%02dT%02d:%02d:%02d</dateTime.iso8601>",
-				t.Year(), t.Month(), t.Day(),
-				t.Hour(), t.Minute(), t.Second(),
-			), nil
+		if t, ok := value.Interface().(time.Time); ok {
+			return fmt.Sprintf("<dateTime.iso8601>%s</dateTime.iso8601>", formatDateTime(t)), nil
 		}
 
 		buf := ""
@@ -230,9 +287,8 @@ func marshalReplyParam(value *reflect.Value) (string, error) {
 		for i := 0; i < numFields; i++ {
 			fieldType := value.Type().Field(i)
 			name := fieldType.Name
-			xmlTag := fieldType.Tag.Get("xml")
-			if xmlTag != "" {
-				name = xmlTag
+			if xmlName, _ := parseTag(fieldType.Tag.Get("xml")); xmlName != "" {
+				name = xmlName
 			}
 
 			field := value.Field(i)
@@ -248,34 +304,207 @@ func marshalReplyParam(value *reflect.Value) (string, error) {
 			)
 		}
 		return fmt.Sprintf("<struct>%s</struct>", buf), nil
-	case reflect.Ptr:
-		return fmt.Sprintf("<nil/>"), nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return "<nil/>", nil
+		}
+		elem := value.Elem()
+		return marshalReplyParam(&elem)
 	default:
 		return "", fmt.Errorf("unknown reply value type '%v'", value.Kind())
 	}
-	return "", nil
 }
 
-func mapValueToField(value interface{}, field *reflect.Value) error {
+// marshalInt emits integers that don't fit the 32-bit <int> as <i8>.
+func marshalInt(i int64) string {
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return fmt.Sprintf("<i8>%d</i8>", i)
+	}
+	return fmt.Sprintf("<int>%d</int>", i)
+}
+
+// marshalMap emits a map as a struct, with members sorted by key.
+func marshalMap(value *reflect.Value) (string, error) {
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	s := Struct{}
+	for _, key := range keys {
+		s = append(s, Member{
+			Name:  fmt.Sprint(key.Interface()),
+			Value: value.MapIndex(key).Interface(),
+		})
+	}
+
+	return marshalStruct(s)
+}
+
+// rawXML is marshalled verbatim, for replies assembled from other responses.
+type rawXML string
+
+// Member is a single member of a Struct.
+type Member struct {
+	Name  string
+	Value interface{}
+}
+
+// Struct is an XML-RPC struct whose members are only known at runtime, e.g.
+// because the client asked for a subset of fields. Members are marshalled in
+// order.
+type Struct []Member
+
+func marshalStruct(s Struct) (string, error) {
+	buf := ""
+	for _, member := range s {
+		memberXML := "<nil/>"
+		if member.Value != nil {
+			value := reflect.ValueOf(member.Value)
+			var err error
+			if memberXML, err = marshalReplyParam(&value); err != nil {
+				return "", err
+			}
+		}
+
+		var name bytes.Buffer
+		xml.EscapeText(&name, []byte(member.Name))
+
+		buf += fmt.Sprintf(
+			"<member><name>%s</name><value>%s</value></member>",
+			name.String(),
+			memberXML,
+		)
+	}
+	return fmt.Sprintf("<struct>%s</struct>", buf), nil
+}
+
+// parseTag splits an xml struct tag into the member name and its options.
+// The only option the codec understands is "utc", which marks time members
+// whose zoneless values are in UTC rather than the codec's Location (e.g.
+// post_date_gmt).
+func parseTag(tag string) (string, []string) {
+	toks := strings.Split(tag, ",")
+	return toks[0], toks[1:]
+}
+
+// errMismatch returns the fault for a value that can't be mapped onto a field
+// of the given type.
+func errMismatch(value, field interface{}) error {
+	return &FaultError{StatusCode: http.StatusBadRequest, Text: fmt.Sprintf("value type mismatch: (%v; %v)", value, field)}
+}
+
+// mapValueToField maps a decoded value onto an args field. Zoneless times
+// are interpreted in loc.
+func mapValueToField(value interface{}, field *reflect.Value, loc *time.Location) error {
+	// XMLRPCValue fields receive the decoded value as is.
+	if field.Type() == reflect.TypeOf(XMLRPCValue{}) {
+		field.Set(reflect.ValueOf(XMLRPCValue{Value: value}))
+		return nil
+	}
+
+	// Nil values leave the field at its zero value.
+	if value == nil {
+		return nil
+	}
+
 	valueKind := reflect.TypeOf(value).Kind()
 	fieldKind := field.Kind()
 
+	// Pointer fields are only allocated when the corresponding value is
+	// present, which lets callers tell omitted members apart from empty ones.
+	if fieldKind == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		elem := ptr.Elem()
+		if err := mapValueToField(value, &elem, loc); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if fieldKind == reflect.Interface {
+		field.Set(reflect.ValueOf(plainValue(value, loc)))
+		return nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		switch fieldKind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(int64(v))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(uint64(v))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			field.SetFloat(float64(v))
+			return nil
+		case reflect.String:
+			// Some clients send numeric IDs where we expect strings.
+			field.SetString(strconv.Itoa(v))
+			return nil
+		}
+	case float64:
+		if fieldKind == reflect.Float32 || fieldKind == reflect.Float64 {
+			field.SetFloat(v)
+			return nil
+		}
+	case []byte:
+		if fieldKind == reflect.String {
+			field.SetString(string(v))
+			return nil
+		}
+		if field.Type() == reflect.TypeOf(v) {
+			field.SetBytes(v)
+			return nil
+		}
+	case time.Time:
+		if field.Type() != reflect.TypeOf(v) {
+			return errMismatch(reflect.TypeOf(v), field.Type())
+		}
+		field.Set(reflect.ValueOf(resolveTime(v, loc)))
+		return nil
+	case XMLRPCStruct:
+		if fieldKind == reflect.Map {
+			return mapStructToMap(v, field, loc)
+		}
+		// Times are structs too, but only dateTime values can set them.
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			return errMismatch(reflect.TypeOf(v), field.Type())
+		}
+	}
+
 	if valueKind != fieldKind {
-		return fmt.Errorf("value type mismatch: (%v; %v)", valueKind, fieldKind)
+		return errMismatch(valueKind, fieldKind)
 	}
 
 	if valueKind == reflect.Struct {
-		xs := value.(XMLRPCStruct)
+		xs, ok := value.(XMLRPCStruct)
+		if !ok {
+			return errMismatch(reflect.TypeOf(value), field.Type())
+		}
 		fieldType := field.Type()
 
 		for i := 0; i < len(xs.Members); i++ {
 			member := xs.Members[i]
 			for j := 0; j < fieldType.NumField(); j++ {
 				structField := fieldType.Field(j)
-				xmlTag := structField.Tag.Get("xml")
-				if xmlTag == member.Name || strings.Title(member.Name) == structField.Name {
+				if structField.PkgPath != "" {
+					// unexported fields can't be set
+					continue
+				}
+				xmlName, opts := parseTag(structField.Tag.Get("xml"))
+				if (xmlName != "" && xmlName == member.Name) || strings.Title(member.Name) == structField.Name {
+					memberLoc := loc
+					for _, opt := range opts {
+						if opt == "utc" {
+							memberLoc = time.UTC
+						}
+					}
+
 					targetField := field.FieldByName(structField.Name)
-					if err := mapValueToField(member.Value.Value, &targetField); err != nil {
+					if err := mapValueToField(member.Value.Value, &targetField, memberLoc); err != nil {
 						return err
 					}
 					break
@@ -283,19 +512,74 @@ func mapValueToField(value interface{}, field *reflect.Value) error {
 			}
 		}
 	} else if valueKind == reflect.Slice {
-		xs := value.([]XMLRPCValue)
+		// Base64 values decode to []byte, which only byte slices (handled
+		// above) can receive.
+		xs, ok := value.([]XMLRPCValue)
+		if !ok {
+			return errMismatch(reflect.TypeOf(value), field.Type())
+		}
 		slice := reflect.MakeSlice(reflect.TypeOf(field.Interface()), len(xs), len(xs))
 		for i, xv := range xs {
 			item := slice.Index(i)
-			if err := mapValueToField(xv.Value, &item); err != nil {
+			if err := mapValueToField(xv.Value, &item, loc); err != nil {
 				log.WithError(err).Error("error mapping element of slice")
 				return err
 			}
 		}
-		field.Set(reflect.AppendSlice(*field, slice))
+		if field.IsNil() {
+			// keep empty arrays distinguishable from absent members
+			field.Set(slice)
+		} else {
+			field.Set(reflect.AppendSlice(*field, slice))
+		}
 	} else {
-		field.Set(reflect.ValueOf(value))
+		field.Set(reflect.ValueOf(value).Convert(field.Type()))
 	}
 
 	return nil
 }
+
+// mapStructToMap maps the members of an XML-RPC struct onto a map field with
+// string keys.
+func mapStructToMap(xs XMLRPCStruct, field *reflect.Value, loc *time.Location) error {
+	mapType := field.Type()
+	if mapType.Key().Kind() != reflect.String {
+		return errMismatch("struct", mapType)
+	}
+
+	m := reflect.MakeMapWithSize(mapType, len(xs.Members))
+	for _, member := range xs.Members {
+		elem := reflect.New(mapType.Elem()).Elem()
+		if err := mapValueToField(member.Value.Value, &elem, loc); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(member.Name).Convert(mapType.Key()), elem)
+	}
+	field.Set(m)
+
+	return nil
+}
+
+// plainValue converts a decoded value into plain Go values, for fields of
+// interface type: structs become map[string]interface{} and arrays become
+// []interface{}.
+func plainValue(value interface{}, loc *time.Location) interface{} {
+	switch v := value.(type) {
+	case XMLRPCStruct:
+		m := make(map[string]interface{}, len(v.Members))
+		for _, member := range v.Members {
+			m[member.Name] = plainValue(member.Value.Value, loc)
+		}
+		return m
+	case []XMLRPCValue:
+		a := make([]interface{}, len(v))
+		for i, xv := range v {
+			a[i] = plainValue(xv.Value, loc)
+		}
+		return a
+	case time.Time:
+		return resolveTime(v, loc)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,65 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testMember struct {
+	Name  string    `xml:"name"`
+	Date  time.Time `xml:"date_gmt,utc"`
+	Count int
+}
+
+func TestMarshalReplyParam(t *testing.T) {
+	chicago := time.FixedZone("CST", -6*60*60)
+	var nilPtr *string
+	str := "x"
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"string", "a < b", "<string>a &lt; b</string>"},
+		{"int", 42, "<int>42</int>"},
+		{"large int", int64(1) << 40, "<i8>1099511627776</i8>"},
+		{"uint", uint(7), "<int>7</int>"},
+		{"double", 1.5, "<double>1.5</double>"},
+		{"true", true, "<boolean>1</boolean>"},
+		{"false", false, "<boolean>0</boolean>"},
+		{"utc time", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "<dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601>"},
+		{"local time", time.Date(2020, 1, 2, 3, 4, 5, 0, chicago), "<dateTime.iso8601>20200102T03:04:05</dateTime.iso8601>"},
+		{"bytes", []byte("hello"), "<base64>aGVsbG8=</base64>"},
+		{"slice", []string{"a", "b"}, "<array><data><value><string>a</string></value><value><string>b</string></value></data></array>"},
+		{"empty slice", []int{}, "<array><data></data></array>"},
+		{"map", map[string]int{"b": 2, "a": 1}, "<struct><member><name>a</name><value><int>1</int></value></member><member><name>b</name><value><int>2</int></value></member></struct>"},
+		{
+			"struct",
+			testMember{Name: "n", Date: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Count: 1},
+			"<struct><member><name>name</name><value><string>n</string></value></member>" +
+				"<member><name>date_gmt</name><value><dateTime.iso8601>20200102T03:04:05Z</dateTime.iso8601></value></member>" +
+				"<member><name>Count</name><value><int>1</int></value></member></struct>",
+		},
+		{"Struct", Struct{{Name: "b", Value: 1}, {Name: "a", Value: "x"}}, "<struct><member><name>b</name><value><int>1</int></value></member><member><name>a</name><value><string>x</string></value></member></struct>"},
+		{"nil pointer", nilPtr, "<nil/>"},
+		{"pointer", &str, "<string>x</string>"},
+		{"rawXML", rawXML("<int>1</int>"), "<int>1</int>"},
+		{"XMLRPCValue", XMLRPCValue{Value: []XMLRPCValue{{Value: 1}}}, "<array><data><value><int>1</int></value></data></array>"},
+		{"nil XMLRPCValue", XMLRPCValue{}, "<nil/>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := reflect.ValueOf(test.value)
+			got, err := marshalReplyParam(&value)
+			if err != nil {
+				t.Fatalf("marshalReplyParam: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}